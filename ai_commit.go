@@ -1,24 +1,615 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/NoamFav/Zvezda/src/ai_commit"
+	"github.com/mattn/go-isatty"
+	flag "github.com/spf13/pflag"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 func main_() {
-	fmt.Println("Getting git info...")
-	prompt := src.GenerateCommitPrompt()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "changelog":
+			changelogCommand(os.Args[2:])
+			return
+		case "release":
+			releaseCommand(os.Args[2:])
+			return
+		case "pr-describe":
+			prDescribeCommand(os.Args[2:])
+			return
+		case "cache":
+			cacheCommand(os.Args[2:])
+			return
+		case "reword", "amend":
+			rewordCommand(os.Args[1], os.Args[2:])
+			return
+		}
+	}
+
+	var yes, staged, pick, split, learnStyle, noCache, sign, installHook, uninstallHook bool
+	var candidates int
+	var messageFile, hookSource, template string
+	var coAuthors []string
+	flag.BoolVarP(&yes, "yes", "y", false,
+		"Commit and push the generated message without the review screen")
+	flag.IntVarP(&candidates, "candidates", "n", 1,
+		"Number of candidate messages to generate and pick from")
+	flag.BoolVarP(&staged, "staged", "s", false,
+		"Only commit what is already staged")
+	flag.BoolVarP(&pick, "pick", "p", false,
+		"Pick the hunks to stage interactively, then commit only those")
+	flag.BoolVar(&split, "split", false,
+		"Split unrelated changes into a series of commits")
+	flag.BoolVar(&installHook, "install-hook", false,
+		"Install the prepare-commit-msg hook in the current repository")
+	flag.BoolVar(&uninstallHook, "uninstall-hook", false,
+		"Remove the prepare-commit-msg hook from the current repository")
+	flag.StringVarP(&template, "template", "t", "",
+		"Prompt template: conventional, gitmoji, plain or the name of a .tmpl file (default: from the config)")
+	flag.BoolVar(&learnStyle, "learn-style", false,
+		"Show the model recent commits and style statistics of the repository")
+	flag.BoolVar(&noCache, "no-cache", false,
+		"Ask the model even if a message was cached for the same changes")
+	flag.BoolVarP(&sign, "sign", "S", false,
+		"Sign the commits with GPG or SSH (git commit -S)")
+	flag.StringSliceVar(&coAuthors, "co-author", nil,
+		"Credit a teammate from the config, or \"Name <email>\", with a Co-authored-by trailer (repeatable)")
+	flag.StringVar(&messageFile, "write-message", "",
+		"Write the message for the staged changes into this file (used by the hook)")
+	flag.StringVar(&hookSource, "hook-source", "",
+		"Where the message of --write-message comes from, as git tells prepare-commit-msg (used by the hook)")
+	flag.Parse()
+
+	repo := openRepo()
+	opts := src.Options{
+		StagedOnly: staged || pick,
+		Template:   template,
+		LearnStyle: learnStyle,
+		NoCache:    noCache,
+		Sign:       sign,
+	}
+
+	if len(coAuthors) > 0 {
+		config, err := src.LoadConfig()
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		for _, who := range coAuthors {
+			teammate, ok := src.FindTeammate(config.Teammates, who)
+			if !ok {
+				fmt.Printf("Error: unknown co-author %q, add them to teammates in the config or use \"Name <email>\"\n", who)
+				os.Exit(1)
+			}
+			opts.CoAuthors = append(opts.CoAuthors, teammate)
+		}
+	}
 
+	switch {
+	case installHook:
+		if err := repo.InstallHook(); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Installed the prepare-commit-msg hook.")
+		return
+	case uninstallHook:
+		if err := repo.UninstallHook(); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Removed the prepare-commit-msg hook.")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if messageFile != "" {
+		if src.SkipHookSource(hookSource) {
+			return
+		}
+		writeMessage(ctx, repo, opts, messageFile)
+		return
+	}
+
+	if split {
+		splitCommits(ctx, repo, opts, yes)
+		return
+	}
+
+	if pick {
+		ok, err := repo.PickHunks(ctx)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if !ok {
+			fmt.Println("No hunks selected.")
+			return
+		}
+	}
+
+	fmt.Println("Getting git info...")
+	changes, err := repo.WorkingChanges(opts.StagedOnly)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	prompt, err := repo.GenerateCommitPrompt(changes, opts)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
 	if prompt == "" {
-		fmt.Println(" Nothing to commit.")
+		fmt.Println(" Nothing to commit.")
 		return // This should stop execution here
 	}
 
-	fmt.Println("Asking Ollama...")
-	resp := src.AskOllama(prompt)
-	resp = strings.TrimSpace(resp)
+	// The review screen needs a terminal, batch callers such as
+	// auto_commit run without one
+	if !yes && isatty.IsTerminal(os.Stdin.Fd()) {
+		review, err := repo.Review(ctx, changes, opts, prompt, candidates)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if !review.Accepted {
+			fmt.Println("Aborted.")
+			return
+		}
+
+		fmt.Println("Committing...")
+		opts.CoAuthors = review.CoAuthors
+		if !opts.StagedOnly {
+			if err := repo.Add(); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+		}
+		if err := repo.ScanStaged(); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if err := repo.Commit(review.Message, opts); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if review.Push {
+			if err := repo.CheckIdentity(); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			pushBranch(repo)
+		}
+		return
+	}
+
+	if candidates > 1 {
+		fmt.Printf("Generating %d candidates...\n", candidates)
+		choices, err := repo.GenerateCandidates(ctx, changes, opts, prompt, candidates)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		for i, c := range choices {
+			fmt.Printf("%d. [%s] %s\n", i+1, c.Style, c.Message)
+		}
+		// Without a terminal to choose from, the first candidate wins
+		fmt.Println("Committing...")
+		commitAndPush(repo, opts, choices[0].Message)
+		return
+	}
+
+	fmt.Println("Asking the model...")
+	result, err := repo.GenerateCommitMessage(ctx, changes, opts, prompt, src.Stream{
+		OnToken: func(token string) { fmt.Print(token) }, // real-time print
+		OnReset: func() { fmt.Println("\nRetrying...") },
+	})
+	fmt.Println()
+	if ctx.Err() != nil {
+		fmt.Println("Aborted.")
+		return
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if result.Fallback {
+		fmt.Printf("Using template message (%s): %s\n", result.Reason, result.Message)
+	}
+	if result.Cached {
+		fmt.Println("Reused the message generated earlier for these changes (--no-cache to regenerate)")
+	}
 
 	fmt.Println("Committing...")
-	src.AddCommitPush(resp)
+	commitAndPush(repo, opts, result.Message)
+}
+
+// commitAndPush runs AddCommitPush and reports how the push went
+func commitAndPush(repo *src.Repo, opts src.Options, message string) {
+	result, err := repo.AddCommitPush(message, opts)
+	if result.Status != "" {
+		fmt.Println("Push:", result)
+	}
+	if err != nil {
+		if result.Status == "" {
+			fmt.Println("Error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// pushBranch pushes the current branch and reports how it went
+func pushBranch(repo *src.Repo) {
+	result := repo.PushBranch()
+	fmt.Println("Push:", result)
+	if result.Err != nil {
+		os.Exit(1)
+	}
+}
+
+// splitCommits proposes one commit per group of related changes, lets the
+// user approve them, and creates them in order
+func splitCommits(ctx context.Context, repo *src.Repo, opts src.Options, yes bool) {
+	groups, err := repo.ProposeSplit()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if len(groups) == 0 {
+		fmt.Println(" Nothing to commit.")
+		return
+	}
+
+	fmt.Printf("Generating messages for %d commits...\n", len(groups))
+	err = repo.GenerateSplitMessages(ctx, groups, opts, func(i int) {
+		fmt.Printf("  %d/%d %s\n", i+1, len(groups), groups[i].Name)
+	})
+	if ctx.Err() != nil {
+		fmt.Println("Aborted.")
+		return
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	push := true
+	if !yes && isatty.IsTerminal(os.Stdin.Fd()) {
+		accepted, withPush, err := repo.ReviewSplit(ctx, groups, opts)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if !accepted {
+			fmt.Println("Aborted.")
+			return
+		}
+		push = withPush
+	}
+
+	created, err := repo.CreateSplitCommits(groups, opts)
+	fmt.Printf("Created %d commits.\n", created)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if push && created > 0 {
+		if err := repo.CheckIdentity(); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println("Push:", repo.PushBranch())
+	}
+}
+
+// writeMessage generates a message for what git is about to commit and
+// hands it to the prepare-commit-msg hook through the message file
+func writeMessage(ctx context.Context, repo *src.Repo, opts src.Options, path string) {
+	changes, err := repo.WorkingChanges(true)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	prompt, err := repo.GenerateCommitPrompt(changes, opts)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if prompt == "" {
+		return
+	}
+
+	result, err := repo.GenerateCommitMessage(ctx, changes, opts, prompt, src.Stream{})
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if err := src.WriteMessageFile(path, result.Message); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
+
+// changelogCommand prints or updates the changelog built from the
+// conventional commits in the history
+func changelogCommand(args []string) {
+	fs := flag.NewFlagSet("changelog", flag.ExitOnError)
+	release := fs.String("release", "", "Only the notes of this tag, since the tag before it")
+	version := fs.String("version", "", "Name the changes since the last tag, or in the range, as this version")
+	full := fs.Bool("full", false, "Every tag in the history, not only the unreleased changes")
+	asJSON := fs.Bool("json", false, "Print JSON instead of Markdown")
+	all := fs.Bool("all", false, "Include docs, tests, build and chore commits")
+	update := fs.Bool("update", false, "Update the changelog file in place instead of printing")
+	file := fs.String("file", "CHANGELOG.md", "Changelog file to update")
+	fs.Parse(args)
+
+	spec := fs.Arg(0)
+	if spec != "" && (*release != "" || *full) {
+		fmt.Println("Error: a range cannot be combined with --release or --full")
+		os.Exit(1)
+	}
+
+	repo := openRepo()
+	var releases []src.ChangelogRelease
+	var err error
+	switch {
+	case spec != "":
+		var notes src.ChangelogRelease
+		notes, err = repo.RangeNotes(spec, *version, *all)
+		releases = append(releases, notes)
+	case *release != "":
+		var notes src.ChangelogRelease
+		notes, err = repo.ReleaseNotes(*release, *all)
+		releases = append(releases, notes)
+	case *full:
+		releases, err = repo.FullChangelog(*all)
+	default:
+		var notes src.ChangelogRelease
+		notes, err = repo.UnreleasedNotes(*version, *all)
+		releases = append(releases, notes)
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	if *update {
+		if err := src.UpdateChangelog(*file, releases); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Updated", *file)
+		return
+	}
+
+	if *asJSON {
+		out, err := src.ChangelogJSON(releases)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+		return
+	}
+	for i, r := range releases {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Print(r.Markdown())
+	}
+}
+
+// releaseCommand tags the next semantic version with the release notes
+func releaseCommand(args []string) {
+	fs := flag.NewFlagSet("release", flag.ExitOnError)
+	pre := fs.String("pre", "", "Pre-release identifier, such as rc or beta")
+	push := fs.Bool("push", false, "Push the tag")
+	remote := fs.String("remote", "origin", "Remote to push the tag to")
+	dryRun := fs.Bool("dry-run", false, "Print the next version and notes without tagging")
+	fs.Parse(args)
+
+	repo := openRepo()
+	plan, err := repo.PlanRelease(*pre)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s -> %s (%s)\n\n", withDefault(plan.Current, "no tag"), plan.Next, plan.Bump)
+	fmt.Print(plan.Notes.Markdown())
+	if *dryRun {
+		return
+	}
+
+	if err := repo.CreateRelease(plan, *push, *remote); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	fmt.Println("\nTagged", plan.Next)
+}
+
+// cacheCommand lists or clears the cached commit messages
+func cacheCommand(args []string) {
+	fs := flag.NewFlagSet("cache", flag.ExitOnError)
+	clearAll := fs.Bool("clear", false, "Remove the cached messages")
+	olderThan := fs.Duration("older-than", 0, "With --clear, only remove messages older than this, such as 720h")
+	asJSON := fs.Bool("json", false, "Print JSON instead of a table")
+	fs.Parse(args)
+
+	if *clearAll {
+		var before time.Time
+		if *olderThan > 0 {
+			before = time.Now().Add(-*olderThan)
+		}
+		removed, err := src.ClearCache(before)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %d cached messages.\n", removed)
+		return
+	}
+
+	entries, err := src.CacheEntries()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if *asJSON {
+		out, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	dir, _ := src.CacheDir()
+	fmt.Printf("%d cached messages in %s\n", len(entries), dir)
+	for _, e := range entries {
+		subject, _, _ := strings.Cut(e.Message, "\n")
+		fmt.Printf("\n%s  %s  %s (%s)  %s, %s\n  %s\n", e.Key[:min(12, len(e.Key))], e.Created.Format("2006-01-02 15:04"),
+			filepath.Base(e.Repo), e.Branch, e.Template, e.Model, subject)
+	}
+}
+
+// openRepo opens the repository of the current directory or exits
+func openRepo() *src.Repo {
+	repo, err := src.OpenRepo(".")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	return repo
+}
+
+func withDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// prDescribeCommand prints a pull request title and body for the current
+// branch. With --output the body goes to the file and only the title is
+// printed, for gh pr create --title "$(...)" --body-file.
+func prDescribeCommand(args []string) {
+	fs := flag.NewFlagSet("pr-describe", flag.ExitOnError)
+	base := fs.String("base", "", "Branch the pull request targets (default: the remote default branch)")
+	output := fs.StringP("output", "o", "", "Write the body to this file and print only the title")
+	fs.Parse(args)
+
+	repo := openRepo()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	pr, err := repo.GeneratePRDescription(ctx, *base, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if pr.Fallback {
+		fmt.Fprintln(os.Stderr, "Using the commit list, the model did not produce a description")
+	}
+
+	if *output != "" {
+		if err := os.WriteFile(*output, []byte(pr.Body+"\n"), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		fmt.Println(pr.Title)
+		return
+	}
+	fmt.Printf("%s\n\n%s\n", pr.Title, pr.Body)
+}
+
+// rewordCommand regenerates the messages of existing commits and rewrites
+// the approved ones. amend is reword limited to the last commit.
+func rewordCommand(name string, args []string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	yes := fs.BoolP("yes", "y", false, "Rewrite every changed message without the review screen")
+	force := fs.Bool("force", false, "Also rewrite commits that are already pushed")
+	dryRun := fs.Bool("dry-run", false, "Only print the current and proposed messages")
+	template := fs.StringP("template", "t", "", "Prompt template, see ai_commit --help")
+	learnStyle := fs.Bool("learn-style", false, "Show the model recent commits and style statistics of the repository")
+	sign := fs.BoolP("sign", "S", false, "Sign the rewritten commits")
+	fs.Parse(args)
+
+	spec := fs.Arg(0)
+	if name == "amend" && spec != "" {
+		fmt.Println("Error: amend only rewords the last commit, use reword for a range")
+		os.Exit(1)
+	}
+
+	repo := openRepo()
+	opts := src.Options{Template: *template, LearnStyle: *learnStyle, Sign: *sign}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	commits, err := repo.RewordCommits(spec, *force)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Generating messages for %d commits...\n", len(commits))
+	err = repo.GenerateRewordMessages(ctx, commits, opts, func(i int) {
+		fmt.Printf("  %d/%d %s\n", i+1, len(commits), commits[i].Short())
+	})
+	if ctx.Err() != nil {
+		fmt.Println("Aborted.")
+		return
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	if !*yes && !*dryRun && isatty.IsTerminal(os.Stdin.Fd()) {
+		accepted, err := repo.ReviewReword(ctx, commits, opts)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if !accepted {
+			fmt.Println("Aborted.")
+			return
+		}
+	} else {
+		for _, c := range commits {
+			if !c.Approved {
+				continue
+			}
+			fmt.Printf("\n%s\n- %s\n+ %s\n", c.Short(),
+				strings.ReplaceAll(c.OldMessage, "\n", "\n  "), strings.ReplaceAll(c.Message, "\n", "\n  "))
+		}
+		if *dryRun {
+			fmt.Println("\nNothing rewritten, this was a dry run.")
+			return
+		}
+		if !*yes {
+			fmt.Println("\nNothing rewritten, pass --yes to apply or run in a terminal to review.")
+			return
+		}
+	}
+
+	rewritten, err := repo.ApplyReword(commits, opts)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Rewrote %d commit messages.\n", rewritten)
 }
@@ -0,0 +1,211 @@
+package src
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Flag definitions from flag, pflag, cobra, argparse and click
+	flagDefRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`\bflag\.\w+\(\s*(?:&[\w.\[\]]+,\s*)?"([\w-]+)"`),
+		regexp.MustCompile(`Flags\(\)\.\w+\(\s*(?:&[\w.\[\]]+,\s*)?"([\w-]+)"`),
+		regexp.MustCompile(`add_argument\(\s*(?:["']-\w["'],\s*)?["']--([\w-]+)["']`),
+		regexp.MustCompile(`click\.option\(\s*(?:["']-\w["'],\s*)?["']--([\w-]+)["']`),
+	}
+
+	jiraKeyRegex     = regexp.MustCompile(`\b([A-Z][A-Z0-9]+-\d+)\b`)
+	githubIssueRegex = regexp.MustCompile(`#(\d+)\b`)
+)
+
+// DetectBreakingChanges lists the changes likely to break users of the
+// code: removed or renamed exported Go identifiers, changed exported
+// signatures and deleted command line flags. Main and internal packages
+// have no importers, so only their flags count. A removal is only reported
+// when every changed file of the package parses, the declaration may have
+// moved into one that does not.
+func (r *Repo) DetectBreakingChanges(c Changes) []string {
+	var breaking []string
+
+	for _, pkg := range r.AnalyzeGoChanges(c) {
+		if pkg.Name == "main" || strings.Contains("/"+pkg.Dir+"/", "/internal/") {
+			continue
+		}
+		for _, c := range pkg.Changes {
+			switch c.Change {
+			case "removed":
+				if len(pkg.Unparsed) > 0 {
+					continue
+				}
+				breaking = append(breaking, fmt.Sprintf("%s %s.%s was removed or renamed", c.Kind, pkg.Name, c.Name))
+			case "signature changed":
+				breaking = append(breaking, fmt.Sprintf("%s %s.%s changed from %s to %s", c.Kind, pkg.Name, c.Name, c.OldSignature, c.Signature))
+			}
+		}
+	}
+
+	for _, name := range removedFlags(c.Diff()) {
+		breaking = append(breaking, fmt.Sprintf("the --%s flag was removed", name))
+	}
+	return breaking
+}
+
+// removedFlags returns the flags whose definition was deleted and not
+// added back elsewhere in the diff
+func removedFlags(diff string) []string {
+	removed := make(map[string]bool)
+	added := make(map[string]bool)
+	var order []string
+
+	for _, file := range ParseDiff(diff) {
+		if strings.HasSuffix(file.Path(), "_test.go") {
+			continue
+		}
+		for _, h := range file.Hunks {
+			for _, line := range h.Lines {
+				if line == "" || (line[0] != '+' && line[0] != '-') {
+					continue
+				}
+				for _, re := range flagDefRegexes {
+					for _, m := range re.FindAllStringSubmatch(line[1:], -1) {
+						if line[0] == '+' {
+							added[m[1]] = true
+						} else if !removed[m[1]] {
+							removed[m[1]] = true
+							order = append(order, m[1])
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var result []string
+	for _, name := range order {
+		if !added[name] {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// IssueRefs pulls issue keys out of a branch name, such as ABC-123 from
+// feature/ABC-123-foo or #42 from fix/#42. Fix branches close their issues,
+// other branches only refer to them, which decides the trailer token.
+func IssueRefs(branch string) (string, []string) {
+	var refs []string
+	seen := make(map[string]bool)
+	add := func(ref string) {
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	for _, m := range jiraKeyRegex.FindAllStringSubmatch(branch, -1) {
+		add(m[1])
+	}
+	for _, m := range githubIssueRegex.FindAllStringSubmatch(branch, -1) {
+		add("#" + m[1])
+	}
+
+	token := "Refs"
+	prefix := strings.ToLower(strings.SplitN(branch, "/", 2)[0])
+	if strings.Contains(branch, "/") && (prefix == "fix" || prefix == "bugfix" || prefix == "hotfix") {
+		token = "Closes"
+	}
+	return token, refs
+}
+
+// CommitFooters returns the footers the commit should carry: a BREAKING
+// CHANGE footer for the detected breaking changes and a Refs or Closes
+// trailer for the issues named in the branch
+func (r *Repo) CommitFooters(c Changes) []Footer {
+	var footers []Footer
+	if breaking := r.DetectBreakingChanges(c); len(breaking) > 0 {
+		footers = append(footers, Footer{Token: "BREAKING CHANGE", Value: strings.Join(breaking, "; ")})
+	}
+	branch, _ := r.Branch()
+	if token, refs := IssueRefs(branch); len(refs) > 0 {
+		footers = append(footers, Footer{Token: token, Value: strings.Join(refs, ", ")})
+	}
+	return footers
+}
+
+// ApplyFooters adds the footers the message lacks and the ! marker when a
+// BREAKING CHANGE footer is present. Footers whose token the message
+// already uses are kept as the model wrote them. Messages that are not
+// conventional commits, gitmoji and plain ones, get the footers as a
+// trailer block without the marker.
+func ApplyFooters(message string, footers []Footer) string {
+	if len(footers) == 0 || strings.TrimSpace(message) == "" {
+		return message
+	}
+	commit, err := ParseConventional(message)
+	if err != nil {
+		return appendTrailers(message, footers)
+	}
+
+	for _, f := range footers {
+		if !hasFooter(commit.Footers, f) {
+			commit.Footers = append(commit.Footers, f)
+		}
+		if isBreakingToken(f.Token) {
+			commit.Breaking = true
+		}
+	}
+	return commit.String()
+}
+
+// appendTrailers adds the missing footers to the trailing paragraph of a
+// free form message, or as a new paragraph when it has no trailers yet
+func appendTrailers(message string, footers []Footer) string {
+	message = strings.TrimSpace(message)
+	lines := strings.Split(message, "\n")
+	_, existing := parseBody(lines[1:])
+
+	var missing []string
+	for _, f := range footers {
+		if !hasFooter(existing, f) {
+			missing = append(missing, f.Token+": "+f.Value)
+		}
+	}
+	if len(missing) == 0 {
+		return message
+	}
+	separator := "\n\n"
+	if len(existing) > 0 {
+		separator = "\n"
+	}
+	return message + separator + strings.Join(missing, "\n")
+}
+
+// hasFooter reports whether footers already use the token of f, both
+// spellings of BREAKING CHANGE being the same token
+func hasFooter(footers []Footer, f Footer) bool {
+	for _, existing := range footers {
+		if strings.EqualFold(existing.Token, f.Token) ||
+			(isBreakingToken(existing.Token) && isBreakingToken(f.Token)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBreakingToken(token string) bool {
+	return token == "BREAKING CHANGE" || token == "BREAKING-CHANGE"
+}
+
+// footerContext describes the detected footers for the prompt
+func footerContext(footers []Footer) string {
+	if len(footers) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\nThe following footers will be added to the message, take them into account:\n")
+	for _, f := range footers {
+		fmt.Fprintf(&b, "- %s: %s\n", f.Token, f.Value)
+	}
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package src_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectBreakingChanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string // after the change, "" deletes the file
+		want  []string
+	}{
+		{
+			name: "moved to another file",
+			files: map[string]string{
+				"api/a.go": "package api\n\nfunc Put() {}\n",
+				"api/b.go": "package api\n\nfunc Get() {}\n",
+			},
+		},
+		{
+			name: "moved and file deleted",
+			files: map[string]string{
+				"api/a.go": "",
+				"api/b.go": "package api\n\nfunc Get() {}\n\nfunc Put() {}\n",
+			},
+		},
+		{
+			name: "moved to a file that does not parse",
+			files: map[string]string{
+				"api/a.go": "package api\n\nfunc Put() {}\n",
+				"api/b.go": "package api\n\nfunc Get() {\n",
+			},
+		},
+		{
+			name: "removed",
+			files: map[string]string{
+				"api/a.go": "package api\n\nfunc Put() {}\n",
+			},
+			want: []string{"func api.Get was removed or renamed"},
+		},
+		{
+			name: "signature changed",
+			files: map[string]string{
+				"api/a.go": "package api\n\nfunc Put() {}\n",
+				"api/b.go": "package api\n\nfunc Get(id int) {}\n",
+			},
+			want: []string{"func api.Get changed from Get() to Get(id int)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			commitFile(t, repo.Path, "api/a.go", "package api\n\nfunc Get() {}\n\nfunc Put() {}\n", "feat: add api")
+			for name, content := range tt.files {
+				path := filepath.Join(repo.Path, filepath.FromSlash(name))
+				if content == "" {
+					if err := os.Remove(path); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				writeFile(t, repo.Path, name, content)
+			}
+			git(t, repo.Path, "add", "-A")
+
+			c, err := repo.WorkingChanges(true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := repo.DetectBreakingChanges(c)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("DetectBreakingChanges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
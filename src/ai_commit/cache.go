@@ -0,0 +1,150 @@
+package src
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CacheEntry is a generated message stored for the changes it describes
+type CacheEntry struct {
+	Key      string    `json:"key"`
+	Message  string    `json:"message"`
+	Repo     string    `json:"repo"`
+	Branch   string    `json:"branch"`
+	Template string    `json:"template"`
+	Model    string    `json:"model"` // provider/model
+	Created  time.Time `json:"created"`
+}
+
+// CacheDir returns where generated messages are cached
+func CacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "zvezda", "messages"), nil
+}
+
+func cachePath(key string) (string, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, key+".json"), nil
+}
+
+// CacheKey hashes what a message is generated from: the changes, untracked
+// files included, the prompt template and the provider and model of the
+// config
+func (r *Repo) CacheKey(config Config, c Changes, opts Options) (string, error) {
+	tmpl, err := r.PromptTemplate(opts)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00", config.Provider, config.Model, tmpl.Name, tmpl.text)
+	io.WriteString(h, c.Diff())
+	for _, file := range c.Untracked {
+		data, _ := os.ReadFile(r.Abs(file))
+		fmt.Fprintf(h, "\x00%s\x00%x", file, sha256.Sum256(data))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CachedMessage returns the message cached under key, if any
+func CachedMessage(key string) (CacheEntry, bool) {
+	path, err := cachePath(key)
+	if err != nil {
+		return CacheEntry{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CacheEntry{}, false
+	}
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Message == "" {
+		return CacheEntry{}, false
+	}
+	return entry, true
+}
+
+// SaveCachedMessage stores an entry under its key
+func SaveCachedMessage(entry CacheEntry) error {
+	path, err := cachePath(entry.Key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// CacheEntries lists the cached messages, newest first
+func CacheEntries() ([]CacheEntry, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []CacheEntry
+	for _, file := range files {
+		key, ok := strings.CutSuffix(file.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if entry, ok := CachedMessage(key); ok {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Created.After(entries[j].Created)
+	})
+	return entries, nil
+}
+
+// ClearCache removes the cached messages created before the given time,
+// or all of them when before is zero, and returns how many were removed
+func ClearCache(before time.Time) (int, error) {
+	entries, err := CacheEntries()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		if !before.IsZero() && entry.Created.After(before) {
+			continue
+		}
+		path, err := cachePath(entry.Key)
+		if err != nil {
+			return removed, err
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,95 @@
+package src_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestMessageCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	if _, ok := src.CachedMessage("abc"); ok {
+		t.Fatal("CachedMessage() found a message in an empty cache")
+	}
+
+	now := time.Now()
+	entries := []src.CacheEntry{
+		{Key: "old", Message: "fix: close rows", Created: now.Add(-48 * time.Hour)},
+		{Key: "new", Message: "feat: add endpoint", Created: now},
+	}
+	for _, e := range entries {
+		if err := src.SaveCachedMessage(e); err != nil {
+			t.Fatalf("SaveCachedMessage() error = %v", err)
+		}
+	}
+
+	if e, ok := src.CachedMessage("new"); !ok || e.Message != "feat: add endpoint" {
+		t.Errorf("CachedMessage() = %+v, %v", e, ok)
+	}
+	if list, _ := src.CacheEntries(); len(list) != 2 || list[0].Key != "new" {
+		t.Errorf("CacheEntries() = %+v, want newest first", list)
+	}
+
+	if removed, err := src.ClearCache(now.Add(-time.Hour)); err != nil || removed != 1 {
+		t.Errorf("ClearCache(an hour ago) = %d, %v, want 1", removed, err)
+	}
+	if removed, err := src.ClearCache(time.Time{}); err != nil || removed != 1 {
+		t.Errorf("ClearCache() = %d, %v, want 1", removed, err)
+	}
+}
+
+func TestGenerateCommitMessageCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"feat: add widget\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	repo := newTestRepo(t)
+	t.Setenv("ZVEZDA_PROVIDER", "openai")
+	t.Setenv("ZVEZDA_ENDPOINT", server.URL)
+	t.Setenv("ZVEZDA_MODEL", "test")
+	git(t, repo.Path, "checkout", "-q", "-b", "feature/PAY-42-widget")
+	writeFile(t, repo.Path, "widget.txt", "widget\n")
+	git(t, repo.Path, "add", "widget.txt")
+
+	generate := func() src.GeneratedMessage {
+		t.Helper()
+		changes, err := repo.WorkingChanges(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prompt, err := repo.GenerateCommitPrompt(changes, src.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := repo.GenerateCommitMessage(context.Background(), changes, src.Options{}, prompt, src.Stream{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if result := generate(); result.Cached || result.Message != "feat: add widget\n\nRefs: PAY-42" {
+		t.Errorf("first message = %+v", result)
+	}
+	if list, _ := src.CacheEntries(); len(list) != 1 || list[0].Message != "feat: add widget" {
+		t.Errorf("CacheEntries() = %+v, want the message without footers", list)
+	}
+
+	// The same changes on another branch reuse the message with its refs
+	git(t, repo.Path, "checkout", "-q", "-b", "feature/PAY-77-widget")
+	if result := generate(); !result.Cached || result.Message != "feat: add widget\n\nRefs: PAY-77" {
+		t.Errorf("cached message = %+v", result)
+	}
+	if requests != 1 {
+		t.Errorf("the model was asked %d times, want 1", requests)
+	}
+}
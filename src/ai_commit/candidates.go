@@ -0,0 +1,117 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// CandidateStyle is one way of asking the model for a commit message
+type CandidateStyle struct {
+	Name         string
+	Temperature  float64
+	Instructions string
+}
+
+// CandidateStyles are cycled through when generating several candidates
+var CandidateStyles = []CandidateStyle{
+	{
+		Name:         "terse",
+		Temperature:  0.2,
+		Instructions: "Keep the subject under 50 characters and do not add a body.",
+	},
+	{
+		Name:         "detailed",
+		Temperature:  0.5,
+		Instructions: "Make the subject as specific as possible about what changed, without a body.",
+	},
+	{
+		Name:         "with body",
+		Temperature:  0.7,
+		Instructions: "After the subject add a blank line and a short body of 2 to 4 lines, wrapped at 72 characters, explaining what changed and why.",
+	},
+}
+
+// Candidate is one generated commit message
+type Candidate struct {
+	Style   string
+	Message string
+}
+
+// GenerateCandidates asks the provider for n commit messages in parallel,
+// each with its own style and temperature, and returns them without
+// duplicates. Candidates that fail validation after the repair attempts are
+// dropped; if none survive, the template message is returned.
+func (r *Repo) GenerateCandidates(ctx context.Context, c Changes, opts Options, prompt string, n int) ([]Candidate, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	rules, err := r.Rules(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := NewClient(config, r, c, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if n < 1 {
+		n = 1
+	}
+
+	results := make([]*Candidate, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		style := CandidateStyles[i%len(CandidateStyles)]
+		// Later rounds through the styles get a little more randomness
+		style.Temperature += 0.1 * float64(i/len(CandidateStyles))
+
+		wg.Add(1)
+		go func(i int, style CandidateStyle) {
+			defer wg.Done()
+			styled := *client
+			styled.Temperature = style.Temperature
+			stylePrompt := prompt + "\n\nStyle: " + style.Instructions
+
+			result, err := r.generateValidated(ctx, &styled, c, opts, stylePrompt, rules, config.RepairAttempts, Stream{})
+			if err != nil || result.Fallback {
+				return
+			}
+			results[i] = &Candidate{Style: style.Name, Message: result.Message}
+		}(i, style)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	footers := r.CommitFooters(c)
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	for _, c := range results {
+		if c == nil {
+			continue
+		}
+		c.Message = ApplyFooters(c.Message, footers)
+		key := strings.Join(strings.Fields(strings.ToLower(c.Message)), " ")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		candidates = append(candidates, *c)
+	}
+
+	if len(candidates) == 0 {
+		message, err := r.HeuristicMessage(c, opts)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, Candidate{Style: "template", Message: ApplyFooters(message, footers)})
+	}
+	return candidates, nil
+}
@@ -0,0 +1,419 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ChangelogEntry is one conventional commit in the changelog
+type ChangelogEntry struct {
+	Hash     string   `json:"hash"`
+	Type     string   `json:"type"`
+	Scope    string   `json:"scope,omitempty"`
+	Subject  string   `json:"subject"`
+	Breaking bool     `json:"breaking,omitempty"`
+	Note     string   `json:"breakingNote,omitempty"`
+	Refs     []string `json:"refs,omitempty"`
+}
+
+// ChangelogSection is a Keep-a-Changelog heading such as "Added"
+type ChangelogSection struct {
+	Title   string           `json:"title"`
+	Entries []ChangelogEntry `json:"entries"`
+}
+
+// ChangelogRelease is the changes of one version, or of the unreleased work
+type ChangelogRelease struct {
+	Version  string             `json:"version"`
+	Date     string             `json:"date,omitempty"`
+	Sections []ChangelogSection `json:"sections"`
+}
+
+// ChangelogSections are the Keep-a-Changelog headings in display order.
+// "Reverted" lists revert commits, which undo a change rather than remove a
+// feature. "Other" collects docs, tests, build and chores when they are
+// included.
+var ChangelogSections = []string{"Added", "Changed", "Deprecated", "Removed", "Fixed", "Security", "Reverted", "Other"}
+
+// changelogSection maps a commit to its Keep-a-Changelog heading, or ""
+// for commits that do not concern users of the project
+func changelogSection(commit ConventionalCommit) string {
+	subject := strings.ToLower(commit.Subject)
+	switch {
+	case commit.Type == "revert":
+		return "Reverted"
+	case commit.Scope == "security" || strings.Contains(subject, "security") || strings.Contains(subject, "vulnerab"):
+		return "Security"
+	case strings.HasPrefix(subject, "deprecate"):
+		return "Deprecated"
+	case strings.HasPrefix(subject, "remove") || strings.HasPrefix(subject, "drop"):
+		return "Removed"
+	case commit.Type == "feat":
+		return "Added"
+	case commit.Type == "fix":
+		return "Fixed"
+	case commit.Type == "perf" || commit.Type == "refactor" || commit.Breaking:
+		return "Changed"
+	}
+	return ""
+}
+
+// ConventionalHistory parses the non-merge commits in from..to. An empty
+// from starts at the root commit. Commits that are not conventional are
+// skipped.
+func (r *Repo) ConventionalHistory(from, to string) ([]ChangelogEntry, error) {
+	rev := to
+	if from != "" {
+		rev = from + ".." + to
+	}
+
+	out, err := r.Log("--no-merges", "--format=%h%x1f%B%x1e", rev)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ChangelogEntry
+	for _, record := range strings.Split(out, "\x1e") {
+		hash, message, ok := strings.Cut(strings.TrimSpace(record), "\x1f")
+		if !ok {
+			continue
+		}
+		commit, err := ParseConventional(message)
+		if err != nil {
+			continue
+		}
+
+		entry := ChangelogEntry{
+			Hash:     hash,
+			Type:     commit.Type,
+			Scope:    commit.Scope,
+			Subject:  commit.Subject,
+			Breaking: commit.Breaking,
+		}
+		for _, f := range commit.Footers {
+			switch {
+			case isBreakingToken(f.Token):
+				entry.Note = f.Value
+			case f.Token == "Refs" || f.Token == "Closes" || f.Token == "Fixes":
+				for _, ref := range strings.Split(f.Value, ",") {
+					entry.Refs = append(entry.Refs, strings.TrimSpace(ref))
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// BuildRelease groups the entries by Keep-a-Changelog section, then by
+// scope. Unless all is set, commits that do not concern users (docs, tests,
+// chores...) are left out.
+func BuildRelease(version, date string, entries []ChangelogEntry, all bool) ChangelogRelease {
+	bySection := make(map[string][]ChangelogEntry)
+	for _, e := range entries {
+		section := changelogSection(ConventionalCommit{Type: e.Type, Scope: e.Scope, Subject: e.Subject, Breaking: e.Breaking})
+		if section == "" {
+			if !all {
+				continue
+			}
+			section = "Other"
+		}
+		bySection[section] = append(bySection[section], e)
+	}
+
+	release := ChangelogRelease{Version: version, Date: date}
+	for _, title := range ChangelogSections {
+		entries := bySection[title]
+		if len(entries) == 0 {
+			continue
+		}
+		// Breaking changes first, then by scope, keeping history order
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].Breaking != entries[j].Breaking {
+				return entries[i].Breaking
+			}
+			return entries[i].Scope < entries[j].Scope
+		})
+		release.Sections = append(release.Sections, ChangelogSection{Title: title, Entries: entries})
+	}
+	return release
+}
+
+// Markdown renders the release as a Keep-a-Changelog section
+func (r ChangelogRelease) Markdown() string {
+	var b strings.Builder
+	if r.Version == "Unreleased" || r.Date == "" {
+		fmt.Fprintf(&b, "## [%s]\n", r.Version)
+	} else {
+		fmt.Fprintf(&b, "## [%s] - %s\n", r.Version, r.Date)
+	}
+
+	for _, section := range r.Sections {
+		fmt.Fprintf(&b, "\n### %s\n\n", section.Title)
+		for _, e := range section.Entries {
+			b.WriteString("- ")
+			if e.Breaking {
+				b.WriteString("**BREAKING** ")
+			}
+			if e.Scope != "" {
+				fmt.Fprintf(&b, "**%s:** ", e.Scope)
+			}
+			b.WriteString(e.Subject)
+			if len(e.Refs) > 0 {
+				fmt.Fprintf(&b, " (%s)", strings.Join(e.Refs, ", "))
+			}
+			fmt.Fprintf(&b, " (%s)\n", e.Hash)
+			if e.Note != "" {
+				fmt.Fprintf(&b, "  - %s\n", e.Note)
+			}
+		}
+	}
+	if len(r.Sections) == 0 {
+		b.WriteString("\nNo notable changes.\n")
+	}
+	return b.String()
+}
+
+// ChangelogJSON renders releases as indented JSON
+func ChangelogJSON(releases []ChangelogRelease) (string, error) {
+	out, err := json.MarshalIndent(releases, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
+
+// Tags returns the tags reachable from HEAD, oldest first
+func (r *Repo) Tags() []string {
+	out, err := r.Git("tag", "--merged", "HEAD", "--sort=creatordate")
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(out)
+}
+
+// LatestTag returns the most recent tag reachable from HEAD, or ""
+func (r *Repo) LatestTag() string {
+	out, err := r.Git("describe", "--tags", "--abbrev=0")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// previousTag returns the tag before the given one, or "" for the first
+func (r *Repo) previousTag(tag string) string {
+	out, err := r.Git("describe", "--tags", "--abbrev=0", tag+"^")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+func (r *Repo) refDate(ref string) string {
+	out, err := r.Git("log", "-1", "--format=%cs", ref)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// ReleaseNotes builds the release for one tag, from the tag before it
+func (r *Repo) ReleaseNotes(tag string, all bool) (ChangelogRelease, error) {
+	entries, err := r.ConventionalHistory(r.previousTag(tag), tag)
+	if err != nil {
+		return ChangelogRelease{}, err
+	}
+	return BuildRelease(tag, r.refDate(tag), entries, all), nil
+}
+
+// UnreleasedNotes builds the release for the commits since the last tag.
+// version names it, "Unreleased" when empty.
+func (r *Repo) UnreleasedNotes(version string, all bool) (ChangelogRelease, error) {
+	entries, err := r.ConventionalHistory(r.LatestTag(), "HEAD")
+	if err != nil {
+		return ChangelogRelease{}, err
+	}
+	date := ""
+	if version == "" {
+		version = "Unreleased"
+	} else {
+		date = time.Now().Format("2006-01-02")
+	}
+	return BuildRelease(version, date, entries, all), nil
+}
+
+// RangeNotes builds the release for the commits in a from..to range, or up
+// to a single revision. version names it; when empty, the end of the range,
+// or "Unreleased" when it ends at HEAD.
+func (r *Repo) RangeNotes(spec, version string, all bool) (ChangelogRelease, error) {
+	from, to, ok := strings.Cut(spec, "..")
+	if !ok {
+		from, to = "", spec
+	}
+	if strings.HasPrefix(to, ".") {
+		return ChangelogRelease{}, fmt.Errorf("%q: symmetric ranges are not supported, use from..to", spec)
+	}
+	if to == "" {
+		to = "HEAD"
+	}
+	entries, err := r.ConventionalHistory(from, to)
+	if err != nil {
+		return ChangelogRelease{}, err
+	}
+	if version == "" && to == "HEAD" {
+		return BuildRelease("Unreleased", "", entries, all), nil
+	}
+	if version == "" {
+		version = to
+	}
+	return BuildRelease(version, r.refDate(to), entries, all), nil
+}
+
+// FullChangelog builds one release per tag, newest first, preceded by the
+// unreleased work if there is any
+func (r *Repo) FullChangelog(all bool) ([]ChangelogRelease, error) {
+	var releases []ChangelogRelease
+
+	unreleased, err := r.UnreleasedNotes("", all)
+	if err != nil {
+		return nil, err
+	}
+	if len(unreleased.Sections) > 0 {
+		releases = append(releases, unreleased)
+	}
+
+	tags := r.Tags()
+	for i := len(tags) - 1; i >= 0; i-- {
+		release, err := r.ReleaseNotes(tags[i], all)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+const changelogHeader = `# Changelog
+
+All notable changes to this project will be documented in this file.
+
+The format is based on [Keep a Changelog](https://keepachangelog.com/en/1.1.0/),
+and this project adheres to [Semantic Versioning](https://semver.org/spec/v2.0.0.html).
+`
+
+var releaseHeadingRegex = regexp.MustCompile(`(?m)^## \[([^\]]+)\]`)
+
+// UpdateChangelog writes the releases into a Keep-a-Changelog file, creating
+// it if needed. A release whose version is already in the file replaces that
+// section in place; other releases are inserted in version order, below
+// Unreleased. Releasing a version newer than every release in the file also
+// drops the Unreleased section, whose changes it now holds.
+func UpdateChangelog(path string, releases []ChangelogRelease) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content := string(data)
+	if strings.TrimSpace(content) == "" {
+		content = changelogHeader
+	}
+
+	for i := len(releases) - 1; i >= 0; i-- {
+		release := releases[i]
+		section := release.Markdown()
+		if updated, ok := replaceRelease(content, release.Version, section); ok {
+			content = updated
+			continue
+		}
+		if release.Version != "Unreleased" && newestRelease(content, release.Version) {
+			content = removeRelease(content, "Unreleased")
+		}
+		content = insertRelease(content, release.Version, section)
+	}
+
+	return os.WriteFile(path, []byte(strings.TrimRight(content, "\n")+"\n"), 0o644)
+}
+
+// releaseEnd returns where the section under the i-th release heading ends:
+// the next heading, the link references at the bottom or the end of file
+func releaseEnd(content string, headings [][]int, i int) int {
+	if i+1 < len(headings) {
+		return headings[i+1][0]
+	}
+	if link := strings.Index(content[headings[i][0]:], "\n["); link >= 0 {
+		return headings[i][0] + link + 1
+	}
+	return len(content)
+}
+
+// replaceRelease swaps the section of a version for a new one, ok is false
+// when the version is not in the changelog
+func replaceRelease(content, version, section string) (string, bool) {
+	headings := releaseHeadingRegex.FindAllStringSubmatchIndex(content, -1)
+	for i, h := range headings {
+		if content[h[2]:h[3]] == version {
+			return content[:h[0]] + section + "\n" + content[releaseEnd(content, headings, i):], true
+		}
+	}
+	return content, false
+}
+
+// removeRelease drops the section of a version from the changelog
+func removeRelease(content, version string) string {
+	headings := releaseHeadingRegex.FindAllStringSubmatchIndex(content, -1)
+	for i, h := range headings {
+		if content[h[2]:h[3]] == version {
+			return content[:h[0]] + content[releaseEnd(content, headings, i):]
+		}
+	}
+	return content
+}
+
+// newestRelease reports whether version is newer than every version in the
+// changelog. Versions that are not semantic versions are never the newest,
+// so their Unreleased notes are kept.
+func newestRelease(content, version string) bool {
+	v, ok := ParseVersion(version)
+	if !ok {
+		return false
+	}
+	for _, m := range releaseHeadingRegex.FindAllStringSubmatch(content, -1) {
+		if other, ok := ParseVersion(m[1]); ok && !other.Less(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// insertRelease puts a section above the first older release, Unreleased
+// above every release and versions that do not parse below Unreleased.
+// Releases older than all the others go last, above the link references.
+func insertRelease(content, version, section string) string {
+	headings := releaseHeadingRegex.FindAllStringSubmatchIndex(content, -1)
+	if len(headings) == 0 {
+		return strings.TrimRight(content, "\n") + "\n\n" + section
+	}
+
+	v, semver := ParseVersion(version)
+	for _, h := range headings {
+		other := content[h[2]:h[3]]
+		if version == "Unreleased" {
+			return content[:h[0]] + section + "\n" + content[h[0]:]
+		}
+		if other == "Unreleased" {
+			continue
+		}
+		if o, ok := ParseVersion(other); !semver || (ok && o.Less(v)) {
+			return content[:h[0]] + section + "\n" + content[h[0]:]
+		}
+	}
+
+	end := releaseEnd(content, headings, len(headings)-1)
+	return strings.TrimRight(content[:end], "\n") + "\n\n" + section + "\n" + content[end:]
+}
@@ -0,0 +1,195 @@
+package src_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestBuildRelease(t *testing.T) {
+	entries := []src.ChangelogEntry{
+		{Hash: "a1", Type: "fix", Scope: "db", Subject: "close rows after query"},
+		{Hash: "b2", Type: "feat", Scope: "api", Subject: "add user endpoint"},
+		{Hash: "c3", Type: "docs", Subject: "describe install steps"},
+		{Hash: "d4", Type: "feat", Scope: "cli", Subject: "rename the config flag", Breaking: true, Note: "--cfg is now --config"},
+	}
+
+	release := src.BuildRelease("1.2.0", "2024-05-01", entries, false)
+	want := `## [1.2.0] - 2024-05-01
+
+### Added
+
+- **BREAKING** **cli:** rename the config flag (d4)
+  - --cfg is now --config
+- **api:** add user endpoint (b2)
+
+### Fixed
+
+- **db:** close rows after query (a1)
+`
+	if got := release.Markdown(); got != want {
+		t.Errorf("Markdown() = %q, want %q", got, want)
+	}
+
+	if all := src.BuildRelease("1.2.0", "", entries, true); len(all.Sections) != 3 || all.Sections[2].Title != "Other" {
+		t.Errorf("BuildRelease(all) sections = %+v", all.Sections)
+	}
+}
+
+func TestBuildReleaseReverts(t *testing.T) {
+	entries := []src.ChangelogEntry{
+		{Hash: "a1", Type: "revert", Subject: "add user cache"},
+		{Hash: "b2", Type: "refactor", Subject: "remove the legacy exporter"},
+	}
+
+	release := src.BuildRelease("1.2.0", "", entries, false)
+	var titles []string
+	for _, s := range release.Sections {
+		titles = append(titles, s.Title)
+	}
+	if got := strings.Join(titles, ","); got != "Removed,Reverted" {
+		t.Errorf("BuildRelease() sections = %s, want Removed,Reverted", got)
+	}
+}
+
+func TestRangeNotes(t *testing.T) {
+	repo := newTestRepo(t)
+	dir := repo.Path
+	commitFile(t, dir, "a.txt", "a\n", "feat: add a")
+	git(t, dir, "tag", "v1.0.0")
+	commitFile(t, dir, "b.txt", "b\n", "fix: handle b")
+	git(t, dir, "tag", "v1.1.0")
+	commitFile(t, dir, "c.txt", "c\n", "feat: add c")
+
+	tests := []struct {
+		spec    string
+		version string
+		want    string
+	}{
+		{"v1.0.0..v1.1.0", "", "v1.1.0: handle b"},
+		{"v1.0.0..", "", "Unreleased: add c, handle b"},
+		{"v1.1.0..HEAD", "2.0.0", "2.0.0: add c"},
+		{"v1.0.0", "", "v1.0.0: add a"},
+	}
+
+	for _, tt := range tests {
+		release, err := repo.RangeNotes(tt.spec, tt.version, false)
+		if err != nil {
+			t.Fatalf("RangeNotes(%q): %v", tt.spec, err)
+		}
+		var subjects []string
+		for _, s := range release.Sections {
+			for _, e := range s.Entries {
+				subjects = append(subjects, e.Subject)
+			}
+		}
+		if got := release.Version + ": " + strings.Join(subjects, ", "); got != tt.want {
+			t.Errorf("RangeNotes(%q) = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+
+	if _, err := repo.RangeNotes("v1.0.0...v1.1.0", "", false); err == nil {
+		t.Error("RangeNotes() accepted a symmetric range")
+	}
+}
+
+func TestUpdateChangelog(t *testing.T) {
+	const existing = `# Changelog
+
+## [Unreleased]
+
+### Added
+
+- add c (c3)
+
+## [1.1.0] - 2024-02-01
+
+### Fixed
+
+- handle b (b2)
+
+## [0.9.0] - 2023-12-01
+
+### Added
+
+- add a (a0)
+
+[1.1.0]: https://example.com/1.1.0
+`
+	release := func(version, subject string) src.ChangelogRelease {
+		return src.BuildRelease(version, "2024-03-01", []src.ChangelogEntry{{Hash: "x", Type: "feat", Subject: subject}}, false)
+	}
+
+	tests := []struct {
+		name     string
+		releases []src.ChangelogRelease
+		want     []string // release headings in file order
+	}{
+		{
+			name:     "older release",
+			releases: []src.ChangelogRelease{release("1.0.0", "add a")},
+			want:     []string{"Unreleased", "1.1.0", "1.0.0", "0.9.0"},
+		},
+		{
+			name:     "oldest release",
+			releases: []src.ChangelogRelease{release("0.1.0", "init")},
+			want:     []string{"Unreleased", "1.1.0", "0.9.0", "0.1.0"},
+		},
+		{
+			name:     "existing release",
+			releases: []src.ChangelogRelease{release("1.1.0", "handle b again")},
+			want:     []string{"Unreleased", "1.1.0", "0.9.0"},
+		},
+		{
+			name:     "newest release",
+			releases: []src.ChangelogRelease{release("1.2.0", "add c")},
+			want:     []string{"1.2.0", "1.1.0", "0.9.0"},
+		},
+		{
+			name:     "unreleased",
+			releases: []src.ChangelogRelease{release("Unreleased", "add d")},
+			want:     []string{"Unreleased", "1.1.0", "0.9.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "CHANGELOG.md")
+			if err := os.WriteFile(path, []byte(existing), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := src.UpdateChangelog(path, tt.releases); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content := string(data)
+
+			var got []string
+			for _, line := range strings.Split(content, "\n") {
+				if version, ok := strings.CutPrefix(line, "## ["); ok {
+					got = append(got, version[:strings.Index(version, "]")])
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("releases = %v, want %v\n%s", got, tt.want, content)
+			}
+			for _, r := range tt.releases {
+				if !strings.Contains(content, r.Markdown()+"\n") {
+					t.Errorf("changelog lacks the %s section\n%s", r.Version, content)
+				}
+			}
+			if tt.releases[0].Version != "Unreleased" && (got[0] == "Unreleased") != strings.Contains(content, "add c (c3)") {
+				t.Errorf("Unreleased notes out of sync with the section\n%s", content)
+			}
+			if !strings.HasSuffix(content, "\n\n[1.1.0]: https://example.com/1.1.0\n") {
+				t.Errorf("link references moved\n%s", content)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package src
+
+import "os"
+
+// Changes are what a commit message is generated for: the changes about to
+// be committed, see WorkingChanges, or those of an existing commit, see
+// CommitChanges. The prompt, the detection helpers and the Go analysis read
+// them instead of asking git for the current diff.
+type Changes struct {
+	Staged        string // diff of the index, or of the commit against its parent
+	Unstaged      string // diff of the working tree, "" when only the index is committed
+	StagedFiles   []string
+	UnstagedFiles []string
+	Untracked     []string // new files that get committed, they have no diff
+
+	// Base is the revision the changes start from. Target is where the
+	// changed files are read: "" for the working tree, ":" for the index,
+	// or a commit or tree.
+	Base   string
+	Target string
+}
+
+// WorkingChanges reads the changes about to be committed: the index, plus
+// the working tree and untracked files unless stagedOnly is set
+func (r *Repo) WorkingChanges(stagedOnly bool) (Changes, error) {
+	c := Changes{Base: "HEAD", Target: ":"}
+	var err error
+	if c.Staged, err = r.StagedDiff(); err != nil {
+		return c, err
+	}
+	c.StagedFiles, _ = r.StagedFiles()
+	if stagedOnly {
+		return c, nil
+	}
+
+	c.Target = ""
+	if c.Unstaged, err = r.Diff(); err != nil {
+		return c, err
+	}
+	c.UnstagedFiles, _ = r.ChangedFiles()
+	c.Untracked, _ = r.UntrackedFiles()
+	return c, nil
+}
+
+// CommitChanges reads the changes an existing commit made to its first
+// parent, so a message can be generated for it again
+func (r *Repo) CommitChanges(rev string) (Changes, error) {
+	c := Changes{Base: rev + "^", Target: rev}
+	var err error
+	if c.Staged, err = r.Git("show", "--format=", "--no-color", rev); err != nil {
+		return c, err
+	}
+	c.StagedFiles, err = r.gitLines("show", "--format=", "--name-only", rev)
+	return c, err
+}
+
+// Files returns the changed files, unstaged ones first, possibly twice
+func (c Changes) Files() []string {
+	return append(append([]string{}, c.UnstagedFiles...), c.StagedFiles...)
+}
+
+// Diff returns the unstaged and the staged diff
+func (c Changes) Diff() string {
+	return c.Unstaged + c.Staged
+}
+
+// fileBefore returns a changed file as it was at Base
+func (r *Repo) fileBefore(c Changes, file string) []byte {
+	data, _ := r.Show(c.Base + ":" + file)
+	return data
+}
+
+// fileAfter returns a changed file as it is at Target
+func (r *Repo) fileAfter(c Changes, file string) []byte {
+	var data []byte
+	switch c.Target {
+	case "":
+		data, _ = os.ReadFile(r.Abs(file))
+	case ":":
+		data, _ = r.Show(":" + file)
+	default:
+		data, _ = r.Show(c.Target + ":" + file)
+	}
+	return data
+}
@@ -0,0 +1,121 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client wraps a Provider with timeouts, retries and cancellation
+type Client struct {
+	Provider    Provider
+	Temperature float64
+	Timeout     time.Duration
+	Retries     int
+	Backoff     time.Duration
+}
+
+// NewClient builds a client for the provider selected in the config, see
+// NewProvider
+func NewClient(config Config, repo *Repo, changes Changes, opts Options) (*Client, error) {
+	provider, err := NewProvider(config, repo, changes, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		Provider:    provider,
+		Temperature: config.Temperature,
+		Timeout:     config.Timeout,
+		Retries:     config.Retries,
+		Backoff:     config.Backoff,
+	}, nil
+}
+
+// Ask sends the prompt to the provider, retrying transient failures with
+// exponential backoff. The stream may be empty.
+func (c *Client) Ask(ctx context.Context, prompt string, stream Stream) (string, error) {
+	return c.AskWith(ctx, Request{Prompt: prompt, Temperature: c.Temperature, Stream: stream})
+}
+
+// AskWith is like Ask but lets the caller control the full request. The text
+// of a failed attempt is discarded with OnReset, or held back until the
+// attempt succeeds when the stream has no OnReset.
+func (c *Client) AskWith(ctx context.Context, req Request) (string, error) {
+	var lastErr error
+	delay := c.Backoff
+
+	for attempt := 0; attempt <= c.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		attempt := req
+		var held strings.Builder
+		streamed := false
+		if req.OnToken != nil {
+			attempt.OnToken = func(token string) {
+				if req.OnReset == nil {
+					held.WriteString(token)
+					return
+				}
+				streamed = true
+				req.OnToken(token)
+			}
+		}
+
+		resp, err := c.generate(ctx, attempt)
+		if err == nil {
+			if held.Len() > 0 {
+				req.OnToken(held.String())
+			}
+			return resp, nil
+		}
+		lastErr = err
+		if streamed {
+			req.OnReset()
+		}
+
+		if ctx.Err() != nil || !isRetryable(err) {
+			break
+		}
+	}
+
+	return "", fmt.Errorf("%s: %w", c.Provider.Name(), lastErr)
+}
+
+func (c *Client) generate(ctx context.Context, req Request) (string, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	return c.Provider.Generate(ctx, req)
+}
+
+// isRetryable reports whether a failed request is worth sending again
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var streamErr *StreamError
+	if errors.As(err, &streamErr) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500 || statusErr.Code == http.StatusTooManyRequests
+	}
+
+	// Connection failures and timeouts
+	return true
+}
@@ -0,0 +1,90 @@
+package src_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+// flakyProvider streams part of a message and fails before answering
+type flakyProvider struct {
+	failures int
+}
+
+func (p *flakyProvider) Name() string { return "flaky" }
+
+func (p *flakyProvider) Generate(ctx context.Context, req src.Request) (string, error) {
+	if p.failures > 0 {
+		p.failures--
+		req.OnToken("feat: half")
+		return "", errors.New("connection reset")
+	}
+	req.OnToken("feat: whole")
+	return "feat: whole", nil
+}
+
+func TestAskRetryStream(t *testing.T) {
+	var events []string
+	onToken := func(token string) { events = append(events, token) }
+	onReset := func() { events = append(events, "<reset>") }
+
+	tests := []struct {
+		name   string
+		stream src.Stream
+		want   string
+	}{
+		{"reset", src.Stream{OnToken: onToken, OnReset: onReset}, "feat: half,<reset>,feat: whole"},
+		{"held back", src.Stream{OnToken: onToken}, "feat: whole"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events = nil
+			client := &src.Client{Provider: &flakyProvider{failures: 1}, Retries: 1}
+			resp, err := client.Ask(context.Background(), "prompt", tt.stream)
+			if err != nil || resp != "feat: whole" {
+				t.Fatalf("Ask() = %q, %v", resp, err)
+			}
+			if got := strings.Join(events, ","); got != tt.want {
+				t.Errorf("streamed %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIStreamEnd(t *testing.T) {
+	const chunk = "data: {\"choices\":[{\"delta\":{\"content\":\"feat: add\"}}]}\n\n"
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"done", chunk + "data: [DONE]\n\n", false},
+		{"finish reason", chunk + "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n", false},
+		{"truncated", chunk, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			provider := &src.OpenAIProvider{Endpoint: server.URL}
+			resp, err := provider.Generate(context.Background(), src.Request{Prompt: "prompt"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate() = %q, %v, want error %v", resp, err, tt.wantErr)
+			}
+			if resp != "feat: add" {
+				t.Errorf("Generate() = %q, want %q", resp, "feat: add")
+			}
+		})
+	}
+}
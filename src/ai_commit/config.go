@@ -0,0 +1,148 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the user settings for the commit generator
+type Config struct {
+	Provider    string  `yaml:"provider"`
+	Model       string  `yaml:"model"`
+	Endpoint    string  `yaml:"endpoint"`
+	APIKey      string  `yaml:"api_key"`
+	Temperature float64 `yaml:"temperature"`
+
+	// Template is the prompt template used unless the repository or the
+	// command line selects another, see LoadPromptTemplate
+	Template string `yaml:"template"`
+
+	// LearnStyle samples StyleSamples well-formed subjects from the history
+	// so generated messages follow the style of each repository
+	LearnStyle   bool `yaml:"learn_style"`
+	StyleSamples int  `yaml:"style_samples"`
+
+	// Sign signs every commit with git commit -S, SigningFormat (gpg, ssh
+	// or x509) and SigningKey override the git config when set
+	Sign          bool   `yaml:"sign"`
+	SigningFormat string `yaml:"signing_format"`
+	SigningKey    string `yaml:"signing_key"`
+
+	// Identities pick the author and signing key by directory, Teammates
+	// can be added as co-authors
+	Identities []Identity `yaml:"identities"`
+	Teammates  []Teammate `yaml:"teammates"`
+
+	// Noise adds patterns of files summarized in one line instead of shown
+	// as a diff, for every repository, see NoiseRules
+	Noise NoiseRules `yaml:"noise"`
+
+	// ProtectedBranches are globs of branches that are never pushed to, and
+	// OnRejected is what a push rejected because the remote moved ahead
+	// does: "rebase" to pull --rebase and push again, or "stop"
+	ProtectedBranches []string `yaml:"protected_branches"`
+	OnRejected        string   `yaml:"on_rejected"`
+
+	// ContextTokens is the model context size, the diff summary in the
+	// prompt is kept within it
+	ContextTokens int `yaml:"context_tokens"`
+
+	// RepairAttempts is how many times an invalid message is sent back to
+	// the model with the violations before falling back to a template
+	RepairAttempts int `yaml:"repair_attempts"`
+
+	// Timeout bounds a single request, Retries and Backoff control how
+	// transient failures are retried
+	Timeout time.Duration `yaml:"timeout"`
+	Retries int           `yaml:"retries"`
+	Backoff time.Duration `yaml:"backoff"`
+}
+
+// DefaultConfig returns the settings used when no config file is present
+func DefaultConfig() Config {
+	return Config{
+		Provider:       "ollama",
+		Model:          "mistral",
+		Temperature:    0.2,
+		ContextTokens:  4096,
+		RepairAttempts: 2,
+		Timeout:        2 * time.Minute,
+		Retries:        2,
+		Backoff:        time.Second,
+		StyleSamples:   20,
+		OnRejected:     OnRejectedRebase,
+	}
+}
+
+// ConfigPath returns the location of the user config file
+func ConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "zvezda", "config.yml"), nil
+}
+
+// LoadConfig reads the user config file and applies environment overrides.
+// A missing config file is not an error, the defaults are used instead.
+func LoadConfig() (Config, error) {
+	config := DefaultConfig()
+
+	path, err := ConfigPath()
+	if err != nil {
+		return config, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return config, err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+
+	// Environment variables win over the config file
+	if v := os.Getenv("ZVEZDA_PROVIDER"); v != "" {
+		config.Provider = v
+	}
+	if v := os.Getenv("ZVEZDA_MODEL"); v != "" {
+		config.Model = v
+	}
+	if v := os.Getenv("ZVEZDA_ENDPOINT"); v != "" {
+		config.Endpoint = v
+	}
+	if v := os.Getenv("ZVEZDA_API_KEY"); v != "" {
+		config.APIKey = v
+	}
+	if v := os.Getenv("ZVEZDA_TEMPLATE"); v != "" {
+		config.Template = v
+	}
+	if v := os.Getenv("ZVEZDA_TEMPERATURE"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return config, fmt.Errorf("invalid ZVEZDA_TEMPERATURE %q: %w", v, err)
+		}
+		config.Temperature = t
+	}
+
+	return config, nil
+}
+
+// DiffBudget returns how many tokens of the context the diff summary may
+// use, leaving room for the instructions and the answer
+func (c Config) DiffBudget() int {
+	reserve := 1024
+	if c.ContextTokens <= 2*reserve {
+		return c.ContextTokens / 2
+	}
+	return c.ContextTokens - reserve
+}
@@ -0,0 +1,163 @@
+package src
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultTypes are the commit types accepted by conventional commits
+var DefaultTypes = []string{
+	"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert",
+}
+
+// Footer is a "Token: value" trailer at the end of a commit message
+type Footer struct {
+	Token string
+	Value string
+}
+
+// ConventionalCommit is a parsed conventional commit message
+type ConventionalCommit struct {
+	Type     string
+	Scope    string
+	Breaking bool
+	Subject  string
+	Body     string
+	Footers  []Footer
+}
+
+var (
+	headerRegex = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^()]*)\))?(!)?: (.*)$`)
+	footerRegex = regexp.MustCompile(`^(BREAKING CHANGE|BREAKING-CHANGE|[A-Za-z][A-Za-z-]*)(?:: | #)(.+)$`)
+)
+
+// ParseConventional parses a commit message of the form
+// <type>(<scope>)!: <subject>, followed by an optional body and footers
+func ParseConventional(message string) (ConventionalCommit, error) {
+	var commit ConventionalCommit
+
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	matches := headerRegex.FindStringSubmatch(strings.TrimSpace(lines[0]))
+	if matches == nil {
+		return commit, fmt.Errorf("header %q is not <type>(<scope>): <subject>", lines[0])
+	}
+	commit.Type = matches[1]
+	commit.Scope = matches[2]
+	commit.Breaking = matches[3] == "!"
+	commit.Subject = matches[4]
+
+	commit.Body, commit.Footers = parseBody(lines[1:])
+
+	for _, f := range commit.Footers {
+		if f.Token == "BREAKING CHANGE" || f.Token == "BREAKING-CHANGE" {
+			commit.Breaking = true
+		}
+	}
+
+	return commit, nil
+}
+
+// parseBody splits the lines after the header into the body and the
+// footers. Footers are the trailing paragraph when every line in it is a
+// footer.
+func parseBody(lines []string) (string, []Footer) {
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	paragraphs := strings.Split(strings.TrimSpace(strings.Join(lines, "\n")), "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+	var footers []Footer
+	for _, line := range strings.Split(last, "\n") {
+		m := footerRegex.FindStringSubmatch(line)
+		if m == nil {
+			footers = nil
+			break
+		}
+		footers = append(footers, Footer{Token: m[1], Value: m[2]})
+	}
+	if footers != nil {
+		paragraphs = paragraphs[:len(paragraphs)-1]
+	}
+	return strings.Join(paragraphs, "\n\n"), footers
+}
+
+// String formats the commit back into a message
+func (c ConventionalCommit) String() string {
+	var b strings.Builder
+	b.WriteString(c.Type)
+	if c.Scope != "" {
+		b.WriteString("(" + c.Scope + ")")
+	}
+	if c.Breaking {
+		b.WriteString("!")
+	}
+	b.WriteString(": " + c.Subject)
+	if c.Body != "" {
+		b.WriteString("\n\n" + c.Body)
+	}
+	if len(c.Footers) > 0 {
+		b.WriteString("\n")
+		for _, f := range c.Footers {
+			b.WriteString("\n" + f.Token + ": " + f.Value)
+		}
+	}
+	return b.String()
+}
+
+var preambleRegex = regexp.MustCompile(`(?i)^(here('s| is)|sure|certainly|okay|ok|the commit message|commit message|suggested commit)\b.*$`)
+
+// CleanResponse strips the chatter models like to wrap a commit message in:
+// preambles, code fences, quotes and trailing explanations
+func CleanResponse(resp string) string {
+	lines := strings.Split(strings.ReplaceAll(resp, "\r\n", "\n"), "\n")
+
+	var kept []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			continue
+		}
+		kept = append(kept, strings.TrimRight(line, " \t"))
+	}
+
+	// Start at the first line that looks like a commit header
+	start := -1
+	for i, line := range kept {
+		if headerRegex.MatchString(unquote(strings.TrimSpace(line))) {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		// No header found, drop obvious preamble lines and hope for the best
+		for len(kept) > 0 && (strings.TrimSpace(kept[0]) == "" || preambleRegex.MatchString(strings.TrimSpace(kept[0]))) {
+			kept = kept[1:]
+		}
+		return unquote(strings.TrimSpace(strings.Join(kept, "\n")))
+	}
+
+	kept = kept[start:]
+	kept[0] = unquote(strings.TrimSpace(kept[0]))
+
+	// Cut trailing explanations such as "This commit message ..." or "Note: ..."
+	for i := 1; i < len(kept); i++ {
+		trimmed := strings.TrimSpace(kept[i])
+		if strings.HasPrefix(trimmed, "This commit") || strings.HasPrefix(trimmed, "Note:") || strings.HasPrefix(trimmed, "Explanation:") {
+			kept = kept[:i]
+			break
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+func unquote(s string) string {
+	for _, q := range []string{"`", "\"", "'"} {
+		if len(s) >= 2 && strings.HasPrefix(s, q) && strings.HasSuffix(s, q) {
+			s = s[1 : len(s)-1]
+		}
+	}
+	return s
+}
@@ -0,0 +1,170 @@
+package src_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestCleanResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		resp string
+		want string
+	}{
+		{
+			name: "plain message",
+			resp: "feat(api): add user endpoint",
+			want: "feat(api): add user endpoint",
+		},
+		{
+			name: "preamble and code fence",
+			resp: "Here is your commit message:\n\n```\nfix(db): close rows after query\n```\n",
+			want: "fix(db): close rows after query",
+		},
+		{
+			name: "quoted with trailing explanation",
+			resp: "\"docs: describe install steps\"\n\nThis commit message follows the conventional format.",
+			want: "docs: describe install steps",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := src.CleanResponse(tt.resp)
+			if got != tt.want {
+				t.Errorf("CleanResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		valid   bool
+	}{
+		{name: "valid header", message: "feat(api): add user endpoint", valid: true},
+		{name: "valid with body and footer", message: "fix: handle empty input\n\nReturn early instead of panicking.\n\nRefs: #42", valid: true},
+		{name: "unknown type", message: "feature: add user endpoint", valid: false},
+		{name: "past tense", message: "fix: fixed the crash", valid: false},
+		{name: "capitalized", message: "docs: Update readme", valid: false},
+		{name: "trailing period", message: "chore: bump deps.", valid: false},
+		{name: "no header", message: "Update some files", valid: false},
+		{name: "missing blank line", message: "feat: add x\nmore text", valid: false},
+		{name: "header at the limit", message: "feat(api): " + strings.Repeat("x", 61), valid: true},
+		{name: "header too long", message: "feat(api): " + strings.Repeat("x", 62), valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := src.Validate(tt.message, src.DefaultRules())
+			if (len(violations) == 0) != tt.valid {
+				t.Errorf("Validate(%q) = %v, want valid %v", tt.message, violations, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateFormats(t *testing.T) {
+	tests := []struct {
+		format  string
+		message string
+		valid   bool
+	}{
+		{format: "gitmoji", message: "✨ add user endpoint", valid: true},
+		{format: "gitmoji", message: ":bug: close rows after query\n\nRefs: #42", valid: true},
+		{format: "gitmoji", message: "feat: add user endpoint", valid: false},
+		{format: "gitmoji", message: "🐛 fixed the crash", valid: false},
+		{format: "plain", message: "Add user endpoint", valid: true},
+		{format: "plain", message: "Add user endpoint.", valid: false},
+		{format: "plain", message: "feat(api): add user endpoint", valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+" "+tt.message, func(t *testing.T) {
+			rules := src.DefaultRules()
+			rules.Format = tt.format
+			violations := src.Validate(tt.message, rules)
+			if (len(violations) == 0) != tt.valid {
+				t.Errorf("Validate(%q) = %v, want valid %v", tt.message, violations, tt.valid)
+			}
+		})
+	}
+}
+
+func TestParseConventional(t *testing.T) {
+	commit, err := src.ParseConventional("feat(cli)!: drop --legacy flag\n\nThe flag was deprecated.\n\nBREAKING CHANGE: --legacy is gone\nRefs: #7")
+	if err != nil {
+		t.Fatalf("ParseConventional() error = %v", err)
+	}
+	if commit.Type != "feat" || commit.Scope != "cli" || !commit.Breaking {
+		t.Errorf("ParseConventional() header = %+v", commit)
+	}
+	if commit.Body != "The flag was deprecated." {
+		t.Errorf("ParseConventional() body = %q", commit.Body)
+	}
+	if len(commit.Footers) != 2 || commit.Footers[1].Value != "#7" {
+		t.Errorf("ParseConventional() footers = %+v", commit.Footers)
+	}
+}
+
+func TestApplyFooters(t *testing.T) {
+	footers := []src.Footer{
+		{Token: "BREAKING CHANGE", Value: "func api.Serve was removed or renamed"},
+		{Token: "Refs", Value: "ABC-123"},
+	}
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "adds marker and footers",
+			message: "refactor(api): split server setup",
+			want:    "refactor(api)!: split server setup\n\nBREAKING CHANGE: func api.Serve was removed or renamed\nRefs: ABC-123",
+		},
+		{
+			name:    "keeps footers the model wrote",
+			message: "refactor(api)!: split server setup\n\nBREAKING CHANGE: Serve is now Run",
+			want:    "refactor(api)!: split server setup\n\nBREAKING CHANGE: Serve is now Run\nRefs: ABC-123",
+		},
+		{
+			name:    "appends trailers to free form messages",
+			message: "Split server setup\n\nMoves the listener out of main.",
+			want:    "Split server setup\n\nMoves the listener out of main.\n\nBREAKING CHANGE: func api.Serve was removed or renamed\nRefs: ABC-123",
+		},
+		{
+			name:    "extends the trailers of gitmoji messages",
+			message: ":recycle: split server setup\n\nRefs: XYZ-9",
+			want:    ":recycle: split server setup\n\nRefs: XYZ-9\nBREAKING CHANGE: func api.Serve was removed or renamed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := src.ApplyFooters(tt.message, footers)
+			if got != tt.want {
+				t.Errorf("ApplyFooters() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssueRefs(t *testing.T) {
+	tests := []struct {
+		branch string
+		token  string
+		refs   []string
+	}{
+		{branch: "feature/ABC-123-foo", token: "Refs", refs: []string{"ABC-123"}},
+		{branch: "fix/#42", token: "Closes", refs: []string{"#42"}},
+		{branch: "main", token: "Refs", refs: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			token, refs := src.IssueRefs(tt.branch)
+			if token != tt.token || strings.Join(refs, ",") != strings.Join(tt.refs, ",") {
+				t.Errorf("IssueRefs(%q) = %q %v, want %q %v", tt.branch, token, refs, tt.token, tt.refs)
+			}
+		})
+	}
+}
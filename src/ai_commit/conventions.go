@@ -0,0 +1,150 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ScopeRule maps files matching a path glob to a commit scope
+type ScopeRule struct {
+	Glob  string `yaml:"glob"`
+	Scope string `yaml:"scope"`
+}
+
+// FooterRule is a footer every commit must carry, such as a ticket ID.
+// Pattern, if set, is a regular expression the footer value must match.
+type FooterRule struct {
+	Token   string `yaml:"token"`
+	Pattern string `yaml:"pattern"`
+}
+
+// Conventions are the per repository commit rules read from .zvezda.yml
+type Conventions struct {
+	Types             []string     `yaml:"types"`
+	Scopes            []ScopeRule  `yaml:"scopes"`
+	HeaderMaxLength   int          `yaml:"header_max_length"`
+	RequiredFooters   []FooterRule `yaml:"required_footers"`
+	PromptPreamble    string       `yaml:"prompt_preamble"`
+	Template          string       `yaml:"template"`
+	Noise             NoiseRules   `yaml:"noise"`
+	ProtectedBranches []string     `yaml:"protected_branches"`
+}
+
+// ConventionFiles are the names looked up at the repository root
+var ConventionFiles = []string{".zvezda.yml", ".zvezda.yaml"}
+
+// LoadConventions reads the conventions file at the root of a repository.
+// A repository without one gets empty conventions.
+func LoadConventions(root string) (Conventions, error) {
+	var conventions Conventions
+
+	for _, name := range ConventionFiles {
+		path := filepath.Join(root, name)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return conventions, err
+		}
+		if err := yaml.Unmarshal(data, &conventions); err != nil {
+			return conventions, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		for _, f := range conventions.RequiredFooters {
+			if _, err := regexp.Compile(f.Pattern); err != nil {
+				return conventions, fmt.Errorf("%s: invalid pattern for footer %q: %w", path, f.Token, err)
+			}
+		}
+		return conventions, nil
+	}
+
+	return conventions, nil
+}
+
+// Rules returns the validation rules with the conventions applied
+func (c Conventions) Rules() Rules {
+	rules := DefaultRules()
+	if len(c.Types) > 0 {
+		rules.Types = c.Types
+	}
+	if c.HeaderMaxLength > 0 {
+		rules.HeaderMaxLength = c.HeaderMaxLength
+	}
+	rules.RequiredFooters = c.RequiredFooters
+	return rules
+}
+
+// AllowsType reports whether the commit type is permitted
+func (c Conventions) AllowsType(commitType string) bool {
+	if len(c.Types) == 0 {
+		return contains(DefaultTypes, commitType)
+	}
+	return contains(c.Types, commitType)
+}
+
+// ScopeFor returns the scope of the first rule matching any of the files.
+// When the files match rules with different scopes, the commit gets no
+// scope: it returns "" and true.
+func (c Conventions) ScopeFor(files []string) (string, bool) {
+	scopes := make(map[string]bool)
+	var first string
+	for _, file := range files {
+		for _, rule := range c.Scopes {
+			if MatchGlob(rule.Glob, file) {
+				if first == "" {
+					first = rule.Scope
+				}
+				scopes[rule.Scope] = true
+				break
+			}
+		}
+	}
+
+	switch len(scopes) {
+	case 0:
+		return "", false
+	case 1:
+		return first, true
+	default:
+		return "", true
+	}
+}
+
+// MatchGlob matches a slash separated path against a glob where * matches
+// within a path segment and ** matches across segments
+func MatchGlob(pattern, path string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				// "**/" also matches zero directories
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	matched, err := regexp.MatchString(b.String(), filepath.ToSlash(path))
+	return err == nil && matched
+}
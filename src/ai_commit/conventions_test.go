@@ -0,0 +1,86 @@
+package src_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"cmd/*", "cmd/main.go", true},
+		{"cmd/*", "cmd/server/main.go", false},
+		{"cmd/**", "cmd/server/main.go", true},
+		{"**/*.md", "README.md", true},
+		{"**/*.md", "docs/guide/install.md", true},
+		{"src/**/test_*.py", "src/test_app.py", true},
+		{"src/**/test_*.py", "src/pkg/sub/test_app.py", true},
+		{"src/**/test_*.py", "lib/test_app.py", false},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file10.txt", false},
+		{"file?.txt", "a/file1.txt", false},
+		{"v1.0/*", "v1x0/a", false},
+		{"[ab].go", "[ab].go", true},
+	}
+
+	for _, tt := range tests {
+		if got := src.MatchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestScopeFor(t *testing.T) {
+	conventions := src.Conventions{Scopes: []src.ScopeRule{
+		{Glob: "api/**", Scope: "api"},
+		{Glob: "web/**", Scope: "ui"},
+		{Glob: "**/*.md", Scope: "docs"},
+	}}
+
+	tests := []struct {
+		files []string
+		scope string
+		ok    bool
+	}{
+		{[]string{"api/server.go", "api/routes/user.go"}, "api", true},
+		{[]string{"api/server.go", "Makefile"}, "api", true},
+		{[]string{"api/server.go", "web/app.ts"}, "", true},
+		{[]string{"Makefile"}, "", false},
+	}
+
+	for _, tt := range tests {
+		scope, ok := conventions.ScopeFor(tt.files)
+		if scope != tt.scope || ok != tt.ok {
+			t.Errorf("ScopeFor(%q) = %q, %v, want %q, %v", tt.files, scope, ok, tt.scope, tt.ok)
+		}
+	}
+}
+
+func TestRequiredFooterValues(t *testing.T) {
+	required := []src.FooterRule{
+		{Token: "Ticket", Pattern: `^[A-Z]+-\d+$`},
+		{Token: "Refs"},
+	}
+
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"feature/PAY-42-checkout", "[{Ticket PAY-42} {Refs PAY-42}]"},
+		{"fix/#17", "[{Ticket TODO} {Refs #17}]"},
+		{"main", "[{Ticket TODO} {Refs TODO}]"},
+	}
+
+	for _, tt := range tests {
+		if got := fmt.Sprint(src.RequiredFooterValues(required, tt.branch)); got != tt.want {
+			t.Errorf("RequiredFooterValues(%q) = %s, want %s", tt.branch, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package src
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileDiff is the part of a unified diff touching a single file
+type FileDiff struct {
+	OldPath string
+	NewPath string
+	Status  string // "added", "deleted", "renamed", "modified"
+	OldMode string
+	NewMode string
+	Binary  bool
+	Header  []string // raw lines from "diff --git" up to the first hunk
+	Hunks   []Hunk
+}
+
+// Hunk is a single @@ block of a file diff
+type Hunk struct {
+	Header  string // the @@ line, including the function context git adds
+	Lines   []string
+	Added   int
+	Removed int
+}
+
+// Path returns the current path of the file, or the old one if deleted
+func (f FileDiff) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// Added returns the number of added lines across all hunks
+func (f FileDiff) Added() int {
+	total := 0
+	for _, h := range f.Hunks {
+		total += h.Added
+	}
+	return total
+}
+
+// Removed returns the number of removed lines across all hunks
+func (f FileDiff) Removed() int {
+	total := 0
+	for _, h := range f.Hunks {
+		total += h.Removed
+	}
+	return total
+}
+
+// Describe returns a one line description of the file change
+func (f FileDiff) Describe() string {
+	switch {
+	case f.Status == "renamed" && len(f.Hunks) == 0:
+		return fmt.Sprintf("renamed %s -> %s", f.OldPath, f.NewPath)
+	case f.Status == "renamed":
+		return fmt.Sprintf("renamed %s -> %s (+%d -%d)", f.OldPath, f.NewPath, f.Added(), f.Removed())
+	case f.Binary:
+		return fmt.Sprintf("%s %s (binary)", f.Status, f.Path())
+	case f.OldMode != "" && f.NewMode != "" && len(f.Hunks) == 0:
+		return fmt.Sprintf("mode %s -> %s %s", f.OldMode, f.NewMode, f.Path())
+	case f.OldMode != "" && f.NewMode != "":
+		return fmt.Sprintf("%s %s (+%d -%d, mode %s -> %s)", f.Status, f.Path(), f.Added(), f.Removed(), f.OldMode, f.NewMode)
+	default:
+		return fmt.Sprintf("%s %s (+%d -%d)", f.Status, f.Path(), f.Added(), f.Removed())
+	}
+}
+
+// ParseDiff splits the output of git diff into per-file diffs
+func ParseDiff(diff string) []FileDiff {
+	var files []FileDiff
+	var current *FileDiff
+	var hunk *Hunk
+
+	flushHunk := func() {
+		if current != nil && hunk != nil {
+			current.Hunks = append(current.Hunks, *hunk)
+		}
+		hunk = nil
+	}
+	flushFile := func() {
+		flushHunk()
+		if current != nil {
+			files = append(files, *current)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		if current != nil && hunk == nil && !strings.HasPrefix(line, "@@") && !strings.HasPrefix(line, "diff --git ") {
+			current.Header = append(current.Header, line)
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushFile()
+			current = &FileDiff{Status: "modified", Header: []string{line}}
+			// "diff --git a/x b/x", paths are refined by the ---/+++ lines
+			if parts := strings.SplitN(strings.TrimPrefix(line, "diff --git "), " b/", 2); len(parts) == 2 {
+				current.OldPath = strings.TrimPrefix(parts[0], "a/")
+				current.NewPath = parts[1]
+			}
+		case current == nil:
+			continue
+		case hunk == nil && strings.HasPrefix(line, "new file mode "):
+			current.Status = "added"
+			current.NewMode = strings.TrimPrefix(line, "new file mode ")
+		case hunk == nil && strings.HasPrefix(line, "deleted file mode "):
+			current.Status = "deleted"
+			current.OldMode = strings.TrimPrefix(line, "deleted file mode ")
+		case hunk == nil && strings.HasPrefix(line, "old mode "):
+			current.OldMode = strings.TrimPrefix(line, "old mode ")
+		case hunk == nil && strings.HasPrefix(line, "new mode "):
+			current.NewMode = strings.TrimPrefix(line, "new mode ")
+		case hunk == nil && strings.HasPrefix(line, "rename from "):
+			current.Status = "renamed"
+			current.OldPath = strings.TrimPrefix(line, "rename from ")
+		case hunk == nil && strings.HasPrefix(line, "rename to "):
+			current.Status = "renamed"
+			current.NewPath = strings.TrimPrefix(line, "rename to ")
+		case hunk == nil && strings.HasPrefix(line, "Binary files "):
+			current.Binary = true
+		case hunk == nil && strings.HasPrefix(line, "--- "):
+			if path := strings.TrimPrefix(line, "--- "); path != "/dev/null" {
+				current.OldPath = strings.TrimPrefix(path, "a/")
+			}
+		case hunk == nil && strings.HasPrefix(line, "+++ "):
+			if path := strings.TrimPrefix(line, "+++ "); path != "/dev/null" {
+				current.NewPath = strings.TrimPrefix(path, "b/")
+			} else {
+				current.NewPath = ""
+			}
+		case strings.HasPrefix(line, "@@"):
+			flushHunk()
+			hunk = &Hunk{Header: line}
+		case hunk != nil:
+			hunk.Lines = append(hunk.Lines, line)
+			if strings.HasPrefix(line, "+") {
+				hunk.Added++
+			} else if strings.HasPrefix(line, "-") {
+				hunk.Removed++
+			}
+		}
+	}
+	flushFile()
+
+	return files
+}
+
+// BuildPatch assembles a patch containing only the selected hunks, keyed by
+// [file index, hunk index]. Apply it with git apply --recount so the line
+// numbers of the hunks left out do not matter.
+func BuildPatch(files []FileDiff, selected map[[2]int]bool) string {
+	var b strings.Builder
+	for i, f := range files {
+		var hunks []Hunk
+		for j, h := range f.Hunks {
+			if selected[[2]int{i, j}] {
+				hunks = append(hunks, h)
+			}
+		}
+		if len(hunks) == 0 {
+			continue
+		}
+		b.WriteString(strings.Join(f.Header, "\n") + "\n")
+		for _, h := range hunks {
+			b.WriteString(h.Header + "\n")
+			for _, line := range h.Lines {
+				b.WriteString(line + "\n")
+			}
+		}
+	}
+	return b.String()
+}
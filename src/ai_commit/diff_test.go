@@ -0,0 +1,164 @@
+package src_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestParseDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		diff     string
+		describe []string
+		hunks    []int
+	}{
+		{
+			name:     "modified",
+			diff:     fileDiff("main.go", " a", "-b", "+c", "+d"),
+			describe: []string{"modified main.go (+2 -1)"},
+			hunks:    []int{1},
+		},
+		{
+			name: "added and deleted",
+			diff: "diff --git a/new.go b/new.go\nnew file mode 100644\n--- /dev/null\n+++ b/new.go\n@@ -0,0 +1,2 @@\n+package x\n+\n" +
+				"diff --git a/old.go b/old.go\ndeleted file mode 100644\n--- a/old.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package x\n",
+			describe: []string{"added new.go (+2 -0)", "deleted old.go (+0 -1)"},
+			hunks:    []int{1, 1},
+		},
+		{
+			name:     "pure rename",
+			diff:     "diff --git a/a.go b/b.go\nsimilarity index 100%\nrename from a.go\nrename to b.go\n",
+			describe: []string{"renamed a.go -> b.go"},
+			hunks:    []int{0},
+		},
+		{
+			name: "rename with changes",
+			diff: "diff --git a/src/a.go b/lib/a.go\nsimilarity index 90%\nrename from src/a.go\nrename to lib/a.go\n" +
+				"--- a/src/a.go\n+++ b/lib/a.go\n@@ -1 +1 @@\n-package src\n+package lib\n",
+			describe: []string{"renamed src/a.go -> lib/a.go (+1 -1)"},
+			hunks:    []int{1},
+		},
+		{
+			name:     "binary",
+			diff:     "diff --git a/logo.png b/logo.png\nnew file mode 100644\nindex 0000000..1234567\nBinary files /dev/null and b/logo.png differ\n",
+			describe: []string{"added logo.png (binary)"},
+			hunks:    []int{0},
+		},
+		{
+			name:     "mode change",
+			diff:     "diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n",
+			describe: []string{"mode 100644 -> 100755 run.sh"},
+			hunks:    []int{0},
+		},
+		{
+			name:     "several hunks",
+			diff:     fileDiff("a.go", "-x", "+y") + "@@ -10,2 +10,2 @@ func main() {\n-p\n+q\n",
+			describe: []string{"modified a.go (+2 -2)"},
+			hunks:    []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := src.ParseDiff(tt.diff)
+			if len(files) != len(tt.describe) {
+				t.Fatalf("ParseDiff() returned %d files, want %d", len(files), len(tt.describe))
+			}
+			for i, f := range files {
+				if got := f.Describe(); got != tt.describe[i] {
+					t.Errorf("Describe() = %q, want %q", got, tt.describe[i])
+				}
+				if len(f.Hunks) != tt.hunks[i] {
+					t.Errorf("%s has %d hunks, want %d", f.Path(), len(f.Hunks), tt.hunks[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSummarizeDiff(t *testing.T) {
+	small := fileDiff("main.go", "-a", "+b")
+	var large strings.Builder
+	for i := 0; i < 200; i++ {
+		large.WriteString("+line of generated looking text number\n")
+	}
+	big := fileDiff("big.go", strings.TrimSuffix(large.String(), "\n"))
+	var many strings.Builder
+	for _, name := range []string{"a.go", "b.go", "c.go", "d.go", "e.go", "f.go"} {
+		many.WriteString(fileDiff(name, "-a", "+b"))
+	}
+
+	tests := []struct {
+		name     string
+		diff     string
+		budget   int
+		omitted  int
+		tokens   int
+		files    []string
+		exceeded bool
+		contains []string
+	}{
+		{
+			name:     "fits",
+			diff:     small + big,
+			budget:   10000,
+			contains: []string{"modified main.go (+1 -1)", "+b", "+line of generated"},
+		},
+		{
+			name:     "omitted hunks",
+			diff:     small + big,
+			budget:   100,
+			omitted:  1,
+			tokens:   1950,
+			files:    []string{"big.go"},
+			contains: []string{"+b", "modified big.go (+200 -0)", "@@ -1,2 +1,2 @@"},
+		},
+		{
+			name:     "budget overflow",
+			diff:     many.String(),
+			budget:   15,
+			omitted:  6,
+			tokens:   12,
+			files:    []string{"a.go"},
+			exceeded: true,
+			contains: []string{"modified a.go", "... 5 more files"},
+		},
+		{
+			name:     "files cut with their hunks",
+			diff:     many.String(),
+			budget:   17,
+			omitted:  5,
+			tokens:   10,
+			exceeded: true,
+			contains: []string{"+b", "... 5 more files"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := src.SummarizeDiff(tt.diff, tt.budget)
+			if s.OmittedHunks != tt.omitted {
+				t.Errorf("OmittedHunks = %d, want %d", s.OmittedHunks, tt.omitted)
+			}
+			if s.OmittedTokens != tt.tokens {
+				t.Errorf("OmittedTokens = %d, want %d", s.OmittedTokens, tt.tokens)
+			}
+			if strings.Join(s.OmittedFiles, ",") != strings.Join(tt.files, ",") {
+				t.Errorf("OmittedFiles = %q, want %q", s.OmittedFiles, tt.files)
+			}
+			if s.BudgetExceeded != tt.exceeded {
+				t.Errorf("BudgetExceeded = %v, want %v", s.BudgetExceeded, tt.exceeded)
+			}
+			if (s.Omissions() == "") != (tt.omitted == 0 && !tt.exceeded) {
+				t.Errorf("Omissions() = %q", s.Omissions())
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(s.Text, want) {
+					t.Errorf("summary does not contain %q:\n%s", want, s.Text)
+				}
+			}
+		})
+	}
+}
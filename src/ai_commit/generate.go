@@ -0,0 +1,138 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GeneratedMessage is the outcome of GenerateCommitMessage
+type GeneratedMessage struct {
+	Message    string
+	Attempts   int
+	Violations []string // violations of the last model answer, if any
+	Fallback   bool     // the message was built from the heuristic template
+	Reason     string   // why the fallback was used
+	Cached     bool     // the message was generated earlier for the same changes
+}
+
+// GenerateCommitMessage asks the model for a commit message, validates it
+// and re-prompts with the violations up to config.RepairAttempts times.
+// When the model keeps failing, or cannot be reached, the message falls back
+// to the DetectType/DetectScope template. Breaking change and issue footers
+// from CommitFooters are added afterwards. Only cancellation and config
+// errors are returned as errors.
+//
+// Messages from the model are cached under CacheKey, and reused as long as
+// the changes, template and model stay the same, unless Options.NoCache is
+// set. They are cached without the footers, which depend on the branch and
+// are added again on every use.
+func (r *Repo) GenerateCommitMessage(ctx context.Context, c Changes, opts Options, prompt string, stream Stream) (GeneratedMessage, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return GeneratedMessage{}, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	rules, err := r.Rules(opts)
+	if err != nil {
+		return GeneratedMessage{}, err
+	}
+
+	client, err := NewClient(config, r, c, opts)
+	if err != nil {
+		return GeneratedMessage{}, err
+	}
+
+	// The heuristic provider is cheap and deterministic, nothing to cache
+	key := ""
+	if client.Provider.Name() != "heuristic" {
+		key, _ = r.CacheKey(config, c, opts)
+	}
+	if key != "" && !opts.NoCache {
+		// Messages that break rules changed since are generated again
+		if entry, ok := CachedMessage(key); ok && len(Validate(entry.Message, rules)) == 0 {
+			message := ApplyFooters(entry.Message, r.CommitFooters(c))
+			if stream.OnToken != nil {
+				stream.OnToken(message)
+			}
+			return GeneratedMessage{Message: message, Cached: true}, nil
+		}
+	}
+
+	result, err := r.generateValidated(ctx, client, c, opts, prompt, rules, config.RepairAttempts, stream)
+	if err != nil {
+		return result, err
+	}
+
+	if key != "" && !result.Fallback {
+		branch, _ := r.Branch()
+		// A cache that cannot be written only costs a model call next time
+		SaveCachedMessage(CacheEntry{
+			Key:      key,
+			Message:  result.Message,
+			Repo:     r.Path,
+			Branch:   branch,
+			Template: r.TemplateName(opts),
+			Model:    config.Provider + "/" + config.Model,
+			Created:  time.Now(),
+		})
+	}
+	result.Message = ApplyFooters(result.Message, r.CommitFooters(c))
+	return result, nil
+}
+
+func (r *Repo) generateValidated(ctx context.Context, client *Client, c Changes, opts Options, prompt string, rules Rules, repairs int, stream Stream) (GeneratedMessage, error) {
+	var result GeneratedMessage
+	current := prompt
+
+	for attempt := 0; attempt <= repairs; attempt++ {
+		if attempt > 0 && stream.OnReset != nil {
+			stream.OnReset() // the repaired message replaces the one streamed
+		}
+		result.Attempts++
+		resp, err := client.Ask(ctx, current, stream)
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		if err != nil {
+			return r.fallbackMessage(c, opts, result, err.Error())
+		}
+
+		message := CleanResponse(resp)
+		result.Violations = Validate(message, rules)
+		if len(result.Violations) == 0 {
+			result.Message = message
+			return result, nil
+		}
+
+		current = RepairPrompt(prompt, message, result.Violations)
+	}
+
+	return r.fallbackMessage(c, opts, result, "the model did not produce a valid message")
+}
+
+func (r *Repo) fallbackMessage(c Changes, opts Options, result GeneratedMessage, reason string) (GeneratedMessage, error) {
+	message, err := r.HeuristicMessage(c, opts)
+	if err != nil {
+		return result, err
+	}
+	result.Message = message
+	result.Fallback = true
+	result.Reason = reason
+	return result, nil
+}
+
+// RepairPrompt asks the model to fix a message that failed validation
+func RepairPrompt(prompt, message string, violations []string) string {
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\nYour previous answer was:\n")
+	b.WriteString(message)
+	b.WriteString("\n\nIt breaks these rules:\n")
+	for _, v := range violations {
+		b.WriteString("  - " + v + "\n")
+	}
+	b.WriteString("\nReturn a corrected commit message. ONLY return the commit message, no explanation, no code fences.")
+	return b.String()
+}
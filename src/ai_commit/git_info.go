@@ -2,119 +2,31 @@ package src
 
 import (
 	"fmt"
-	"os/exec"
 	"regexp"
+	"sort"
 	"strings"
 )
 
-// GitDiff returns the current diff output
-func GitDiff() string {
-	cmd := exec.Command("git", "diff")
-	out, err := cmd.Output()
-	if err != nil {
-		fmt.Println("Error getting diff:", err)
-	}
-	return string(out)
-}
-
-// GitStagedDiff returns the diff of staged changes
-func GitStagedDiff() string {
-	cmd := exec.Command("git", "diff", "--staged")
-	out, err := cmd.Output()
-	if err != nil {
-		fmt.Println("Error getting staged diff:", err)
-	}
-	return string(out)
-}
-
-// GitStatus returns the current status in porcelain format
-func GitStatus() string {
-	cmd := exec.Command("git", "status", "--porcelain")
-	out, err := cmd.Output()
-	if err != nil {
-		fmt.Println("Error getting status:", err)
-	}
-	return string(out)
-}
-
-// GitBranch returns the current branch name
-func GitBranch() string {
-	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	out, err := cmd.Output()
-	if err != nil {
-		fmt.Println("Error getting branch:", err)
-		return ""
-	}
-	return strings.TrimSpace(string(out))
-}
-
-// GitLastCommit returns the last commit message
-func GitLastCommit() string {
-	cmd := exec.Command("git", "log", "-1", "--pretty=%B")
-	out, err := cmd.Output()
-	if err != nil {
-		fmt.Println("Error getting last commit:", err)
-		return ""
-	}
-	return strings.TrimSpace(string(out))
-}
-
-// GitChangedFiles returns a list of files that have been changed
-func GitChangedFiles() []string {
-	cmd := exec.Command("git", "diff", "--name-only")
-	out, err := cmd.Output()
-	if err != nil {
-		fmt.Println("Error getting changed files:", err)
-		return []string{}
-	}
-
-	files := strings.Split(strings.TrimSpace(string(out)), "\n")
-	// Filter out empty strings
-	var result []string
-	for _, file := range files {
-		if file != "" {
-			result = append(result, file)
-		}
-	}
-	return result
-}
-
-// GitStagedFiles returns a list of files that have been staged
-func GitStagedFiles() []string {
-	cmd := exec.Command("git", "diff", "--staged", "--name-only")
-	out, err := cmd.Output()
-	if err != nil {
-		fmt.Println("Error getting staged files:", err)
-		return []string{}
-	}
-
-	files := strings.Split(strings.TrimSpace(string(out)), "\n")
-	// Filter out empty strings
-	var result []string
-	for _, file := range files {
-		if file != "" {
-			result = append(result, file)
-		}
-	}
-	return result
-}
-
-// ExtractPackageNames attempts to find what packages were modified
-func ExtractPackageNames() []string {
-	files := append(GitChangedFiles(), GitStagedFiles()...)
+// ExtractPackageNames attempts to find what packages were modified. Go
+// files are resolved to their package clause, falling back to the path
+// when a file cannot be parsed.
+func (r *Repo) ExtractPackageNames(c Changes) []string {
 	packages := make(map[string]bool)
-
-	for _, file := range files {
-		if strings.HasSuffix(file, ".go") {
-			parts := strings.Split(file, "/")
-			if len(parts) > 1 {
-				// Consider the first or second directory as the package
-				// This is a heuristic and might need adjustment for your project structure
-				pkgIndex := 0
-				if len(parts) > 2 && parts[0] == "cmd" || parts[0] == "pkg" || parts[0] == "internal" {
-					pkgIndex = 1
-				}
-				if pkgIndex < len(parts) {
+	for _, pkg := range r.AnalyzeGoChanges(c) {
+		packages[pkg.Scope()] = true
+	}
+
+	files := c.Files()
+	if len(packages) == 0 {
+		for _, file := range files {
+			if strings.HasSuffix(file, ".go") {
+				parts := strings.Split(file, "/")
+				if len(parts) > 1 {
+					// Consider the first or second directory as the package
+					pkgIndex := 0
+					if len(parts) > 2 && (parts[0] == "cmd" || parts[0] == "pkg" || parts[0] == "internal") {
+						pkgIndex = 1
+					}
 					packages[parts[pkgIndex]] = true
 				}
 			}
@@ -126,49 +38,89 @@ func ExtractPackageNames() []string {
 	for pkg := range packages {
 		result = append(result, pkg)
 	}
+	sort.Strings(result)
 	return result
 }
 
-// DetectScope tries to intelligently determine the scope for conventional commits
-func DetectScope() string {
-	packages := ExtractPackageNames()
+// DetectScope tries to intelligently determine the scope for conventional
+// commits. Only an invalid conventions file is returned as an error.
+func (r *Repo) DetectScope(c Changes) (string, error) {
+	// Explicit rules from the repository conventions come first
+	conventions, err := LoadConventions(r.Path)
+	if err != nil {
+		return "", err
+	}
+	if scope, ok := conventions.ScopeFor(c.Files()); ok {
+		return scope, nil
+	}
+
+	// Workspace manifests know which module owns each file. A change
+	// spanning several modules or packages gets no scope.
+	if modules := r.ModuleScopes(c.Files()); len(modules) == 1 {
+		return modules[0], nil
+	} else if len(modules) > 1 {
+		return "", nil
+	}
+
+	packages := r.ExtractPackageNames(c)
 	if len(packages) == 1 {
-		return packages[0]
+		return packages[0], nil
 	} else if len(packages) > 1 {
-		return "multi"
+		return "", nil
 	}
 
 	// If we couldn't detect packages, try to determine if this is a specific type of change
-	files := append(GitChangedFiles(), GitStagedFiles()...)
+	files := c.Files()
 
 	// Check for common patterns
 	for _, file := range files {
 		if strings.Contains(file, "test") || strings.HasSuffix(file, "_test.go") {
-			return "tests"
+			return "tests", nil
 		}
 		if strings.Contains(file, "docs") || strings.HasSuffix(file, ".md") {
-			return "docs"
+			return "docs", nil
 		}
 		if strings.Contains(file, "config") || strings.HasSuffix(file, ".yaml") || strings.HasSuffix(file, ".yml") {
-			return "config"
+			return "config", nil
 		}
 	}
 
 	// Default scope based on branch name
-	branch := GitBranch()
+	branch, _ := r.Branch()
 	scopeRegex := regexp.MustCompile(`(feature|fix|hotfix|chore)/([a-zA-Z0-9_-]+)`)
 	matches := scopeRegex.FindStringSubmatch(branch)
 	if len(matches) >= 3 {
-		return matches[2]
+		return matches[2], nil
 	}
 
-	return ""
+	return "", nil
 }
 
-// DetectType tries to intelligently determine the commit type
-func DetectType() string {
+// DetectType tries to intelligently determine the commit type, restricted
+// to the types allowed by the repository conventions
+func (r *Repo) DetectType(c Changes) (string, error) {
+	conventions, err := LoadConventions(r.Path)
+	if err != nil {
+		return "", err
+	}
+	commitType := r.detectType(c)
+	if conventions.AllowsType(commitType) {
+		return commitType, nil
+	}
+
+	// "config" and friends are not conventional types
+	if conventions.AllowsType("chore") {
+		return "chore", nil
+	}
+	if len(conventions.Types) > 0 {
+		return conventions.Types[0], nil
+	}
+	return "chore", nil
+}
+
+func (r *Repo) detectType(c Changes) string {
 	// First check branch name for hints
-	branch := GitBranch()
+	branch, _ := r.Branch()
 	if strings.HasPrefix(branch, "feature/") {
 		return "feat"
 	}
@@ -180,7 +132,7 @@ func DetectType() string {
 	}
 
 	// Then check files
-	files := append(GitChangedFiles(), GitStagedFiles()...)
+	files := c.Files()
 
 	// Look for testing changes
 	testCount := 0
@@ -210,7 +162,7 @@ func DetectType() string {
 	}
 
 	// Check diff for specific patterns
-	diff := GitDiff() + GitStagedDiff()
+	diff := c.Diff()
 
 	if strings.Contains(strings.ToLower(diff), "fix") ||
 		strings.Contains(strings.ToLower(diff), "bug") ||
@@ -235,24 +187,16 @@ func DetectType() string {
 	return "chore"
 }
 
-// Summary provides a comprehensive summary of repository changes
-func Summary() string {
-	status := GitStatus()
-	// Check if there's nothing to commit
-	if strings.TrimSpace(status) == "" {
-		return ""
-	}
-
-	stagedDiff := GitStagedDiff()
-	diff := GitDiff()
-
+// Summary provides a comprehensive summary of repository changes, or ""
+// when there is nothing to commit
+func (r *Repo) Summary(c Changes) (string, error) {
+	stagedDiff, diff := c.Staged, c.Unstaged
+	stagedFiles, changedFiles := c.StagedFiles, c.UnstagedFiles
 	if strings.TrimSpace(diff) == "" && strings.TrimSpace(stagedDiff) == "" {
-		return ""
+		return "", nil
 	}
 
-	branch := GitBranch()
-	changedFiles := GitChangedFiles()
-	stagedFiles := GitStagedFiles()
+	branch, _ := r.Branch()
 
 	// Create a more detailed summary
 	summary := fmt.Sprintf("Branch: %s\n\n", branch)
@@ -273,96 +217,177 @@ func Summary() string {
 		summary += "\n"
 	}
 
-	if strings.TrimSpace(stagedDiff) != "" {
-		// Limit the diff size to avoid overwhelming the AI
-		if len(stagedDiff) > 2000 {
-			summary += fmt.Sprintf("Staged Git Diff (truncated):\n%s...\n\n", stagedDiff[:2000])
-		} else {
-			summary += fmt.Sprintf("Staged Git Diff:\n%s\n\n", stagedDiff)
-		}
+	// Lockfiles, vendored, generated and binary files only get a line
+	noise := r.NoiseRules()
+	head := func(file string) string {
+		return r.fileHead(c, file)
 	}
+	stagedDiff, stagedNoise := noise.Filter(stagedDiff, head)
+	diff, unstagedNoise := noise.Filter(diff, head)
 
-	if strings.TrimSpace(diff) != "" {
-		// Limit the diff size to avoid overwhelming the AI
-		if len(diff) > 2000 {
-			summary += fmt.Sprintf("Unstaged Git Diff (truncated):\n%s...\n\n", diff[:2000])
-		} else {
-			summary += fmt.Sprintf("Unstaged Git Diff:\n%s\n\n", diff)
-		}
+	// Share the token budget between the staged and unstaged diffs
+	config, _ := LoadConfig()
+	budget := config.DiffBudget()
+	stagedBudget, unstagedBudget := budget, budget
+	if strings.TrimSpace(stagedDiff) != "" && strings.TrimSpace(diff) != "" {
+		stagedBudget = budget * len(stagedDiff) / (len(stagedDiff) + len(diff))
+		unstagedBudget = budget - stagedBudget
+	}
+
+	if strings.TrimSpace(stagedDiff) != "" || len(stagedNoise) > 0 {
+		staged := SummarizeDiff(stagedDiff, stagedBudget)
+		staged.Noise = stagedNoise
+		summary += formatDiffSummary("Staged Git Diff", staged)
+	}
+
+	if strings.TrimSpace(diff) != "" || len(unstagedNoise) > 0 {
+		unstaged := SummarizeDiff(diff, unstagedBudget)
+		unstaged.Noise = unstagedNoise
+		summary += formatDiffSummary("Unstaged Git Diff", unstaged)
+	}
+
+	if goChanges := FormatGoChanges(r.AnalyzeGoChanges(c)); goChanges != "" {
+		summary += "Exported Go API Changes:\n" + goChanges + "\n"
 	}
 
 	// Add suggestions for the commit
-	suggestedType := DetectType()
-	suggestedScope := DetectScope()
+	suggestedType, err := r.DetectType(c)
+	if err != nil {
+		return "", err
+	}
+	suggestedScope, err := r.DetectScope(c)
+	if err != nil {
+		return "", err
+	}
 
 	summary += "Commit Suggestions:\n"
 	summary += fmt.Sprintf("  - Type: %s\n", suggestedType)
 	summary += fmt.Sprintf("  - Scope: %s\n", suggestedScope)
 
-	return summary
+	return summary, nil
 }
 
-// GitAdd stages all changes for commit
-func GitAdd() {
-	cmd := exec.Command("git", "add", ".")
-	out, err := cmd.Output()
-	if err != nil {
-		fmt.Println("Error adding files:", err)
+func formatDiffSummary(title string, diff DiffSummary) string {
+	text := diff.Text
+	if len(diff.Noise) > 0 {
+		text += "Summarized lockfiles, vendored, generated and binary files:\n"
+		for _, line := range diff.Noise {
+			text += "  - " + line + "\n"
+		}
 	}
-	fmt.Println(string(out))
-}
-
-// GitCommit commits staged changes with the provided message
-func GitCommit(message string) {
-	cmd := exec.Command("git", "commit", "-m", message)
-	out, err := cmd.Output()
-	if err != nil {
-		fmt.Println("Error committing:", err)
+	if omitted := diff.Omissions(); omitted != "" {
+		return fmt.Sprintf("%s (summarized, %s):\n%s\n", title, omitted, text)
 	}
-	fmt.Println(string(out))
+	return fmt.Sprintf("%s:\n%s\n", title, text)
 }
 
-// GitPush pushes commits to the remote repository
-func GitPush() {
-	cmd := exec.Command("git", "push")
-	out, err := cmd.Output()
-	if err != nil {
-		fmt.Println("Error pushing:", err)
+// AddCommitPush stages everything, commits and pushes with PushBranch. With
+// Options.StagedOnly nothing is staged and only the index is committed.
+// Nothing is committed when the staged changes hold secrets, see
+// ScanStaged, and nothing is pushed when unpushed commits do not match the
+// identity of the directory, see CheckIdentity. A failed push is returned
+// both as the result and as the error.
+func (r *Repo) AddCommitPush(message string, opts Options) (PushResult, error) {
+	if !opts.StagedOnly {
+		if err := r.Add(); err != nil {
+			return PushResult{}, err
+		}
 	}
-	fmt.Println(string(out))
+	if err := r.ScanStaged(); err != nil {
+		return PushResult{}, err
+	}
+	if err := r.Commit(message, opts); err != nil {
+		return PushResult{}, err
+	}
+	if err := r.CheckIdentity(); err != nil {
+		return PushResult{}, err
+	}
+	result := r.PushBranch()
+	return result, result.Err
 }
 
-// AddCommitPush performs all three operations in sequence
-func AddCommitPush(message string) {
-	GitAdd()
-	GitCommit(message)
-	GitPush()
+func footerInstructions(footers []FooterRule) string {
+	if len(footers) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\nEnd the message with a blank line followed by these footers:\n")
+	for _, f := range footers {
+		if f.Pattern != "" {
+			fmt.Fprintf(&b, "- %s: <value matching %s>\n", f.Token, f.Pattern)
+		} else {
+			fmt.Fprintf(&b, "- %s: <value>\n", f.Token)
+		}
+	}
+	return b.String()
 }
 
-// GenerateCommitPrompt creates an improved prompt for the AI
-func GenerateCommitPrompt() string {
-	summary := Summary()
-
-	// Detect type and scope to provide better context
-	suggestedType := DetectType()
-	suggestedScope := DetectScope()
-
-	prompt := fmt.Sprintf(`You are an AI Git assistant. Your task is to write a conventional commit message in the format:
-<type>(<scope>): <subject>
+// GenerateCommitPrompt renders the prompt template of the repository with
+// the summary of the changes, or returns "" when there is nothing to commit
+func (r *Repo) GenerateCommitPrompt(c Changes, opts Options) (string, error) {
+	summary, err := r.Summary(c)
+	if err != nil || summary == "" {
+		return "", err
+	}
 
-I've analyzed the changes and suggest:
-- Type: %s (but choose the most appropriate from: feat, fix, docs, style, refactor, perf, test, build, ci, chore, revert)
-- Scope: %s (update if you think another scope is more appropriate)
+	tmpl, err := r.PromptTemplate(opts)
+	if err != nil {
+		return "", err
+	}
+	conventions, err := LoadConventions(r.Path)
+	if err != nil {
+		return "", err
+	}
 
-Be concise but descriptive. The subject should:
-- Use imperative, present tense (e.g., "change" not "changed" or "changes")
-- Not capitalize the first letter
-- No period at the end
+	rules, err := r.Rules(opts)
+	if err != nil {
+		return "", err
+	}
+	commitType, err := r.DetectType(c)
+	if err != nil {
+		return "", err
+	}
+	scope, err := r.DetectScope(c)
+	if err != nil {
+		return "", err
+	}
+	branch, _ := r.Branch()
+	style := r.Style(opts)
+	recent := r.RecentSubjects(10)
+	if style != nil {
+		recent = style.Examples
+	}
+	prompt, err := tmpl.Render(PromptData{
+		Branch:        branch,
+		Files:         dedupe(c.Files()),
+		Summary:       summary,
+		Type:          commitType,
+		Scope:         scope,
+		RecentCommits: recent,
+		Style:         style,
+		Conventions:   conventions,
+		Rules:         rules,
+		Footers:       r.CommitFooters(c),
+	})
+	if err != nil {
+		return "", err
+	}
 
-ONLY return the commit message, nothing else.
+	if conventions.PromptPreamble != "" {
+		prompt = strings.TrimSpace(conventions.PromptPreamble) + "\n\n" + prompt
+	}
 
-Repository changes summary:
-%s`, suggestedType, suggestedScope, summary)
+	return prompt, nil
+}
 
-	return prompt
+func dedupe(items []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	return result
 }
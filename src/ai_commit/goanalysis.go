@@ -0,0 +1,283 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GoChange is an exported Go declaration that was added, removed or changed
+type GoChange struct {
+	Kind         string // "func", "method", "type"
+	Name         string // "Foo", or "T.Foo" for methods
+	Change       string // "added", "removed", "signature changed", "modified"
+	Signature    string
+	OldSignature string
+}
+
+// GoPackageChanges groups the changes of one Go package
+type GoPackageChanges struct {
+	Name    string // package clause name
+	Dir     string
+	Changes []GoChange
+
+	// Unparsed are the changed files of the package that did not parse
+	// before or after the changes. Their declarations are unknown, so a
+	// declaration moved into one of them shows up as removed.
+	Unparsed []string
+}
+
+// Scope returns the name best describing the package in a commit scope.
+// main packages are named after their directory.
+func (p GoPackageChanges) Scope() string {
+	if p.Name == "main" && p.Dir != "." && p.Dir != "" {
+		return filepath.Base(p.Dir)
+	}
+	return p.Name
+}
+
+// GoFile is a changed Go source file, Before or After is empty when the
+// file was added or deleted
+type GoFile struct {
+	Name   string
+	Before []byte
+	After  []byte
+}
+
+type goDecl struct {
+	kind      string
+	signature string
+	body      string
+}
+
+// AnalyzeGoChanges parses the versions before and after the changes of
+// every changed non-test .go file and reports the exported functions,
+// methods and types that differ, grouped by package, see CompareGoFiles.
+func (r *Repo) AnalyzeGoChanges(c Changes) []GoPackageChanges {
+	seen := make(map[string]bool)
+	var files []GoFile
+	for _, file := range c.Files() {
+		if seen[file] || !strings.HasSuffix(file, ".go") || strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		seen[file] = true
+		files = append(files, GoFile{Name: file, Before: r.fileBefore(c, file), After: r.fileAfter(c, file)})
+	}
+	return CompareGoFiles(files)
+}
+
+// CompareGoFiles compares the exported declarations of the changed files
+// of each package, keyed by directory and package clause, before and after
+// the changes. The declarations of all the files of a package are merged
+// first, so one moved to another file of the package is not reported.
+// Files that do not parse are left out and listed in Unparsed. Packages
+// whose files changed without touching exported declarations are listed
+// with no changes. Packages are sorted by directory, changes by name.
+func CompareGoFiles(files []GoFile) []GoPackageChanges {
+	type pkgDecls struct {
+		pkg           GoPackageChanges
+		before, after map[string]goDecl
+	}
+	packages := make(map[string]*pkgDecls)
+	get := func(dir, name string) *pkgDecls {
+		key := dir + ":" + name
+		p, ok := packages[key]
+		if !ok {
+			p = &pkgDecls{
+				pkg:    GoPackageChanges{Name: name, Dir: dir},
+				before: make(map[string]goDecl),
+				after:  make(map[string]goDecl),
+			}
+			packages[key] = p
+		}
+		return p
+	}
+
+	for _, f := range files {
+		dir := filepath.Dir(f.Name)
+		oldName, oldDecls, oldOK := parseGoDecls(f.Name, f.Before)
+		newName, newDecls, newOK := parseGoDecls(f.Name, f.After)
+		if !oldOK || !newOK {
+			// Unknown rather than empty, report the package it belongs to
+			if name := firstNonEmpty(newName, oldName); name != "" {
+				p := get(dir, name)
+				p.pkg.Unparsed = append(p.pkg.Unparsed, f.Name)
+			}
+			continue
+		}
+		if oldName != "" {
+			p := get(dir, oldName)
+			for name, d := range oldDecls {
+				p.before[name] = d
+			}
+		}
+		if newName != "" {
+			p := get(dir, newName)
+			for name, d := range newDecls {
+				p.after[name] = d
+			}
+		}
+	}
+
+	var result []GoPackageChanges
+	for _, p := range packages {
+		p.pkg.Changes = diffGoDecls(p.before, p.after)
+		sort.Slice(p.pkg.Changes, func(i, j int) bool {
+			return p.pkg.Changes[i].Name < p.pkg.Changes[j].Name
+		})
+		result = append(result, p.pkg)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Dir != result[j].Dir {
+			return result[i].Dir < result[j].Dir
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseGoDecls returns the package name and the exported declarations of a
+// Go source file. ok is false when the file does not parse; an empty file,
+// one that was added or deleted, has no name and no declarations.
+func parseGoDecls(filename string, src []byte) (name string, decls map[string]goDecl, ok bool) {
+	decls = make(map[string]goDecl)
+	if len(src) == 0 {
+		return "", decls, true
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if err != nil {
+		// The package clause is usually fine even when the rest is not
+		if file, err := parser.ParseFile(fset, filename, src, parser.PackageClauseOnly); err == nil {
+			return file.Name.Name, nil, false
+		}
+		return "", nil, false
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			kind, name := "func", d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiverName(d.Recv.List[0].Type)
+				if !ast.IsExported(recv) {
+					continue
+				}
+				kind, name = "method", recv+"."+d.Name.Name
+			}
+			decls[name] = goDecl{
+				kind:      kind,
+				signature: name + strings.TrimPrefix(nodeString(fset, d.Type), "func"),
+				body:      nodeString(fset, d.Body),
+			}
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if !ts.Name.IsExported() {
+					continue
+				}
+				definition := nodeString(fset, ts.Type)
+				decls[ts.Name.Name] = goDecl{
+					kind:      "type",
+					signature: ts.Name.Name + " " + definition,
+					body:      definition,
+				}
+			}
+		}
+	}
+
+	return file.Name.Name, decls, true
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+func nodeString(fset *token.FileSet, node ast.Node) string {
+	if node == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+func diffGoDecls(before, after map[string]goDecl) []GoChange {
+	var changes []GoChange
+	for name, old := range before {
+		current, ok := after[name]
+		switch {
+		case !ok:
+			changes = append(changes, GoChange{Kind: old.kind, Name: name, Change: "removed", OldSignature: old.signature})
+		case old.signature != current.signature && old.kind != "type":
+			changes = append(changes, GoChange{Kind: current.kind, Name: name, Change: "signature changed", Signature: current.signature, OldSignature: old.signature})
+		case old.body != current.body:
+			changes = append(changes, GoChange{Kind: current.kind, Name: name, Change: "modified", Signature: current.signature, OldSignature: old.signature})
+		}
+	}
+	for name, current := range after {
+		if _, ok := before[name]; !ok {
+			changes = append(changes, GoChange{Kind: current.kind, Name: name, Change: "added", Signature: current.signature})
+		}
+	}
+	return changes
+}
+
+// FormatGoChanges renders the analysis as bullet points for the prompt
+func FormatGoChanges(packages []GoPackageChanges) string {
+	var b strings.Builder
+	for _, pkg := range packages {
+		if len(pkg.Changes) == 0 && len(pkg.Unparsed) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "Package %s (%s):\n", pkg.Name, pkg.Dir)
+		for _, file := range pkg.Unparsed {
+			fmt.Fprintf(&b, "  - %s does not parse, its declarations are unknown\n", file)
+		}
+		for _, c := range pkg.Changes {
+			switch c.Change {
+			case "signature changed":
+				fmt.Fprintf(&b, "  - %s %s: signature changed from %s to %s\n", c.Kind, c.Name, c.OldSignature, c.Signature)
+			case "removed":
+				fmt.Fprintf(&b, "  - removed %s %s\n", c.Kind, c.Name)
+			default:
+				fmt.Fprintf(&b, "  - %s %s %s\n", c.Change, c.Kind, c.Name)
+			}
+		}
+	}
+	return b.String()
+}
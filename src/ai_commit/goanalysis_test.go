@@ -0,0 +1,166 @@
+package src_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func goFile(name, before, after string) src.GoFile {
+	return src.GoFile{Name: name, Before: []byte(before), After: []byte(after)}
+}
+
+func TestCompareGoFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   string
+		after    string
+		files    []src.GoFile // instead of before and after of api.go
+		pkg      string
+		want     []string // "kind name: change"
+		unparsed []string
+	}{
+		{
+			name:  "new file",
+			after: "package api\n\nfunc Get() {}\n\ntype Client struct{}\n\nfunc helper() {}\n",
+			pkg:   "api",
+			want:  []string{"type Client: added", "func Get: added"},
+		},
+		{
+			name:   "deleted file",
+			before: "package api\n\nfunc Get() {}\n",
+			pkg:    "api",
+			want:   []string{"func Get: removed"},
+		},
+		{
+			name:   "signature changed",
+			before: "package api\n\nfunc Get(id int) error { return nil }\n",
+			after:  "package api\n\nfunc Get(id string) error { return nil }\n",
+			pkg:    "api",
+			want:   []string{"func Get: signature changed"},
+		},
+		{
+			name:   "body modified",
+			before: "package api\n\nfunc Get() int { return 1 }\n",
+			after:  "package api\n\nfunc Get() int { return 2 }\n",
+			pkg:    "api",
+			want:   []string{"func Get: modified"},
+		},
+		{
+			name:   "type definition modified",
+			before: "package api\n\ntype Client struct{ URL string }\n",
+			after:  "package api\n\ntype Client struct {\n\tURL     string\n\tTimeout int\n}\n",
+			pkg:    "api",
+			want:   []string{"type Client: modified"},
+		},
+		{
+			name:   "methods",
+			before: "package api\n\ntype Client struct{}\n\nfunc (c *Client) Do() {}\n\ntype conn struct{}\n\nfunc (c conn) Close() {}\n",
+			after:  "package api\n\ntype Client struct{}\n\nfunc (c *Client) Do(retry bool) {}\n\nfunc (c Client) Close() {}\n\ntype conn struct{}\n",
+			pkg:    "api",
+			want:   []string{"method Client.Close: added", "method Client.Do: signature changed"},
+		},
+		{
+			name:   "generic receiver",
+			before: "package set\n\ntype Set[T comparable] map[T]bool\n",
+			after:  "package set\n\ntype Set[T comparable] map[T]bool\n\nfunc (s Set[T]) Has(v T) bool { return s[v] }\n",
+			pkg:    "set",
+			want:   []string{"method Set.Has: added"},
+		},
+		{
+			name:   "unexported only",
+			before: "package api\n\nfunc helper() {}\n",
+			after:  "package api\n\nfunc helper() { println() }\n",
+			pkg:    "api",
+		},
+		{
+			name:     "syntax error",
+			before:   "package api\n\nfunc Get() {}\n",
+			after:    "package api\n\nfunc Get( {\n",
+			pkg:      "api",
+			unparsed: []string{"api.go"},
+		},
+		{
+			name: "moved between files",
+			files: []src.GoFile{
+				goFile("api/a.go", "package api\n\nfunc Get() {}\n\nfunc Put() {}\n", "package api\n\nfunc Put() {}\n"),
+				goFile("api/b.go", "", "package api\n\nfunc Get() {}\n"),
+			},
+			pkg: "api",
+		},
+		{
+			name: "moved and changed",
+			files: []src.GoFile{
+				goFile("api/a.go", "package api\n\nfunc Get() {}\n\nfunc Put() {}\n", "package api\n\nfunc Put() {}\n"),
+				goFile("api/b.go", "package api\n", "package api\n\nfunc Get(id int) {}\n"),
+			},
+			pkg:  "api",
+			want: []string{"func Get: signature changed"},
+		},
+		{
+			name: "moved into a file that does not parse",
+			files: []src.GoFile{
+				goFile("api/a.go", "package api\n\nfunc Get() {}\n", "package api\n"),
+				goFile("api/b.go", "package api\n", "package api\n\nfunc Get() {\n"),
+			},
+			pkg:      "api",
+			want:     []string{"func Get: removed"},
+			unparsed: []string{"api/b.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := tt.files
+			if files == nil {
+				files = []src.GoFile{goFile("api.go", tt.before, tt.after)}
+			}
+			packages := src.CompareGoFiles(files)
+			if len(packages) != 1 {
+				t.Fatalf("packages = %+v, want one", packages)
+			}
+			pkg := packages[0]
+			if pkg.Name != tt.pkg {
+				t.Errorf("package = %q, want %q", pkg.Name, tt.pkg)
+			}
+			if strings.Join(pkg.Unparsed, " ") != strings.Join(tt.unparsed, " ") {
+				t.Errorf("unparsed = %q, want %q", pkg.Unparsed, tt.unparsed)
+			}
+			var got []string
+			for _, c := range pkg.Changes {
+				got = append(got, fmt.Sprintf("%s %s: %s", c.Kind, c.Name, c.Change))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("changes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatGoChanges(t *testing.T) {
+	packages := src.CompareGoFiles([]src.GoFile{
+		goFile("internal/api/client.go",
+			"package api\n\nfunc Get(id int) {}\n\nfunc Old() {}\n",
+			"package api\n\nfunc Get(id string) {}\n\nfunc New() {}\n"),
+		goFile("internal/api/server.go", "package api\n", "package api\n\nfunc Serve( {\n"),
+		goFile("cmd/server/main.go", "package main\n", "package main\n\nfunc main() {}\n"),
+	})
+	if len(packages) != 2 {
+		t.Fatalf("packages = %+v, want two", packages)
+	}
+	packages[0], packages[1] = packages[1], packages[0]
+
+	want := "Package api (internal/api):\n" +
+		"  - internal/api/server.go does not parse, its declarations are unknown\n" +
+		"  - func Get: signature changed from Get(id int) to Get(id string)\n" +
+		"  - added func New\n" +
+		"  - removed func Old\n"
+	if got := src.FormatGoChanges(packages); got != want {
+		t.Errorf("FormatGoChanges() =\n%s\nwant\n%s", got, want)
+	}
+	if got := packages[1].Scope(); got != "server" {
+		t.Errorf("Scope() = %q, want %q", got, "server")
+	}
+}
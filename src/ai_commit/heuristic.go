@@ -0,0 +1,122 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// HeuristicProvider builds a commit message from the detected type, scope
+// and changed files without calling any model. Its output is deterministic,
+// which makes it usable offline and as a fallback.
+type HeuristicProvider struct {
+	Repo    *Repo
+	Changes Changes
+	Options Options
+}
+
+func (p *HeuristicProvider) Name() string {
+	return "heuristic"
+}
+
+func (p *HeuristicProvider) Generate(ctx context.Context, req Request) (string, error) {
+	message, err := p.Repo.HeuristicMessage(p.Changes, p.Options)
+	if err != nil {
+		return "", err
+	}
+	if req.OnToken != nil {
+		req.OnToken(message)
+	}
+	return message, nil
+}
+
+// HeuristicMessage returns a commit message based on DetectType, DetectScope
+// and the list of changed files, in the format of the prompt template, with
+// the footers the conventions require, see RequiredFooterValues
+func (r *Repo) HeuristicMessage(c Changes, opts Options) (string, error) {
+	commitType, err := r.DetectType(c)
+	if err != nil {
+		return "", err
+	}
+	scope, err := r.DetectScope(c)
+	if err != nil {
+		return "", err
+	}
+	conventions, err := LoadConventions(r.Path)
+	if err != nil {
+		return "", err
+	}
+
+	files := dedupe(c.Files())
+
+	target := "files"
+	switch {
+	case len(files) == 1:
+		target = filepath.Base(files[0])
+	case len(files) > 1:
+		target = fmt.Sprintf("%d files", len(files))
+	}
+
+	var subject string
+	switch commitType {
+	case "feat":
+		subject = "add changes to " + target
+	case "fix":
+		subject = "fix issues in " + target
+	case "docs":
+		subject = "update documentation in " + target
+	case "test":
+		subject = "update tests in " + target
+	case "refactor":
+		subject = "refactor " + target
+	default:
+		subject = "update " + target
+	}
+
+	format := "conventional"
+	if tmpl, err := r.PromptTemplate(opts); err == nil {
+		format = tmpl.Format
+	}
+	var message string
+	switch {
+	case format == "gitmoji":
+		message = gitmojiFor(commitType) + " " + subject
+	case format == "plain":
+		message = strings.ToUpper(subject[:1]) + subject[1:]
+	case scope != "":
+		message = fmt.Sprintf("%s(%s): %s", commitType, scope, subject)
+	default:
+		message = fmt.Sprintf("%s: %s", commitType, subject)
+	}
+
+	branch, _ := r.Branch()
+	return ApplyFooters(message, RequiredFooterValues(conventions.RequiredFooters, branch)), nil
+}
+
+// RequiredFooterValues fills in the required footers from the branch name:
+// the first match of the footer pattern, or the issues named in the branch
+// for footers without one. Footers nothing can be found for get TODO, which
+// fails validation and so gets noticed in review.
+func RequiredFooterValues(required []FooterRule, branch string) []Footer {
+	_, refs := IssueRefs(branch)
+
+	var footers []Footer
+	for _, rule := range required {
+		value := ""
+		if rule.Pattern != "" {
+			// Anchors are dropped to search inside the branch name
+			if re, err := regexp.Compile(strings.TrimSuffix(strings.TrimPrefix(rule.Pattern, "^"), "$")); err == nil {
+				value = re.FindString(branch)
+			}
+		} else if len(refs) > 0 {
+			value = strings.Join(refs, ", ")
+		}
+		if value == "" {
+			value = "TODO"
+		}
+		footers = append(footers, Footer{Token: rule.Token, Value: value})
+	}
+	return footers
+}
@@ -0,0 +1,134 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker identifies the hooks written by InstallHook
+const hookMarker = "# zvezda prepare-commit-msg hook"
+
+// chainedHookName is where an existing hook is moved when installing
+const chainedHookName = "prepare-commit-msg.zvezda-orig"
+
+// HookScript runs any hook that was there before, then has ai_commit fill
+// in the generated message. The source git passes is handed over too, see
+// SkipHookSource.
+const HookScript = `#!/bin/sh
+` + hookMarker + `
+MSG_FILE="$1"
+SOURCE="$2"
+
+CHAINED="$(dirname "$0")/` + chainedHookName + `"
+if [ -x "$CHAINED" ]; then
+	"$CHAINED" "$@" || exit $?
+fi
+
+command -v ai_commit >/dev/null 2>&1 || exit 0
+ai_commit --write-message "$MSG_FILE" --hook-source "$SOURCE" >/dev/null 2>&1 </dev/null
+exit 0
+`
+
+// SkipHookSource reports whether the hook leaves the message alone for the
+// source git passes to prepare-commit-msg: messages from -m/-F, merges,
+// squashes and amends (the "message", "merge", "squash" and "commit"
+// sources) already have one.
+func SkipHookSource(source string) bool {
+	switch source {
+	case "message", "merge", "squash", "commit":
+		return true
+	}
+	return false
+}
+
+// hooksDir returns the hooks directory of the repository, honouring
+// core.hooksPath
+func (r *Repo) hooksDir() (string, error) {
+	out, err := r.Git("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	dir := strings.TrimSpace(out)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(r.Path, dir)
+	}
+	return dir, nil
+}
+
+func isOurHook(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.Contains(string(data), hookMarker)
+}
+
+// InstallHook writes the prepare-commit-msg hook into the repository. A
+// hook that is already there is kept and called first. Installing twice is
+// harmless.
+func (r *Repo) InstallHook() error {
+	dir, err := r.hooksDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	hook := filepath.Join(dir, "prepare-commit-msg")
+	if _, err := os.Stat(hook); err == nil && !isOurHook(hook) {
+		chained := filepath.Join(dir, chainedHookName)
+		if _, err := os.Stat(chained); err == nil {
+			return fmt.Errorf("%s already exists, refusing to overwrite it", chained)
+		}
+		if err := os.Rename(hook, chained); err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(hook, []byte(HookScript), 0o755)
+}
+
+// UninstallHook removes the hook written by InstallHook and puts back the
+// hook it was chained to, if any
+func (r *Repo) UninstallHook() error {
+	dir, err := r.hooksDir()
+	if err != nil {
+		return err
+	}
+
+	hook := filepath.Join(dir, "prepare-commit-msg")
+	if _, err := os.Stat(hook); os.IsNotExist(err) {
+		return nil
+	}
+	if !isOurHook(hook) {
+		return fmt.Errorf("%s was not installed by zvezda, leaving it alone", hook)
+	}
+	if err := os.Remove(hook); err != nil {
+		return err
+	}
+
+	chained := filepath.Join(dir, chainedHookName)
+	if _, err := os.Stat(chained); err == nil {
+		return os.Rename(chained, hook)
+	}
+	return nil
+}
+
+// WriteMessageFile puts message at the top of the commit message file git
+// passes to prepare-commit-msg, above the template and the comment lines.
+// Files already holding a message are left untouched.
+func WriteMessageFile(path, message string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "#") {
+			return nil
+		}
+	}
+
+	content := strings.TrimSpace(message) + "\n" + string(data)
+	return os.WriteFile(path, []byte(content), 0o644)
+}
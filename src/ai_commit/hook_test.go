@@ -0,0 +1,70 @@
+package src_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestSkipHookSource(t *testing.T) {
+	tests := []struct {
+		source string
+		skip   bool
+	}{
+		{"", false},
+		{"template", false},
+		{"message", true},
+		{"merge", true},
+		{"squash", true},
+		{"commit", true},
+	}
+
+	for _, tt := range tests {
+		if got := src.SkipHookSource(tt.source); got != tt.skip {
+			t.Errorf("SkipHookSource(%q) = %v, want %v", tt.source, got, tt.skip)
+		}
+	}
+}
+
+func TestWriteMessageFile(t *testing.T) {
+	comments := "\n# Please enter the commit message for your changes.\n# On branch main\n"
+
+	tests := []struct {
+		name    string
+		content *string // nil when git did not create the file
+		want    string
+	}{
+		{"missing file", nil, "feat: add x\n"},
+		{"empty file", ptr(""), "feat: add x\n"},
+		{"comments only", ptr(comments), "feat: add x\n" + comments},
+		{"message given", ptr("fix: typo\n" + comments), "fix: typo\n" + comments},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+			if tt.content != nil {
+				if err := os.WriteFile(path, []byte(*tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := src.WriteMessageFile(path, "  feat: add x\n\n"); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("message file = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
@@ -0,0 +1,190 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type hunkRef struct {
+	file int
+	hunk int
+}
+
+type hunkPickerModel struct {
+	files    []FileDiff
+	hunks    []hunkRef
+	selected map[[2]int]bool
+	cursor   int
+	preview  viewport.Model
+	done     bool
+	accepted bool
+	windowW  int
+}
+
+func newHunkPickerModel(files []FileDiff) hunkPickerModel {
+	m := hunkPickerModel{
+		files:    files,
+		selected: make(map[[2]int]bool),
+		preview:  viewport.New(80, 12),
+	}
+	for i, f := range files {
+		for j := range f.Hunks {
+			m.hunks = append(m.hunks, hunkRef{file: i, hunk: j})
+		}
+	}
+	m.refreshPreview()
+	return m
+}
+
+func (m *hunkPickerModel) refreshPreview() {
+	if len(m.hunks) == 0 {
+		return
+	}
+	ref := m.hunks[m.cursor]
+	h := m.files[ref.file].Hunks[ref.hunk]
+	m.preview.SetContent(colorDiff(h.Header + "\n" + strings.Join(h.Lines, "\n")))
+	m.preview.GotoTop()
+}
+
+func (m hunkPickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m hunkPickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.windowW = msg.Width
+		m.preview.Width = msg.Width - 4
+		m.preview.Height = max(5, msg.Height/2-4)
+		m.refreshPreview()
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			m.done = true
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+				m.refreshPreview()
+			}
+		case "down", "j":
+			if m.cursor < len(m.hunks)-1 {
+				m.cursor++
+				m.refreshPreview()
+			}
+		case " ", "x":
+			if len(m.hunks) > 0 {
+				ref := m.hunks[m.cursor]
+				key := [2]int{ref.file, ref.hunk}
+				m.selected[key] = !m.selected[key]
+			}
+		case "f":
+			// Toggle every hunk of the current file
+			if len(m.hunks) > 0 {
+				file := m.hunks[m.cursor].file
+				all := true
+				for j := range m.files[file].Hunks {
+					all = all && m.selected[[2]int{file, j}]
+				}
+				for j := range m.files[file].Hunks {
+					m.selected[[2]int{file, j}] = !all
+				}
+			}
+		case "a":
+			// Toggle every hunk, deselected ones stay in the map as false
+			all := true
+			for _, ref := range m.hunks {
+				all = all && m.selected[[2]int{ref.file, ref.hunk}]
+			}
+			for _, ref := range m.hunks {
+				m.selected[[2]int{ref.file, ref.hunk}] = !all
+			}
+		case "enter":
+			m.done = true
+			m.accepted = true
+			return m, tea.Quit
+		default:
+			var cmd tea.Cmd
+			m.preview, cmd = m.preview.Update(msg)
+			return m, cmd
+		}
+	}
+	return m, nil
+}
+
+func (m hunkPickerModel) View() string {
+	if m.done {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(reviewTitleStyle.Render(" Select hunks to commit") + "\n\n")
+
+	count := 0
+	lastFile := -1
+	for i, ref := range m.hunks {
+		if ref.file != lastFile {
+			b.WriteString(m.files[ref.file].Describe() + "\n")
+			lastFile = ref.file
+		}
+		cursor := "  "
+		if i == m.cursor {
+			cursor = "> "
+		}
+		check := "[ ]"
+		if m.selected[[2]int{ref.file, ref.hunk}] {
+			check = reviewAddedStyle.Render("[x]")
+			count++
+		}
+		h := m.files[ref.file].Hunks[ref.hunk]
+		b.WriteString(fmt.Sprintf("%s%s %s %s\n", cursor, check, h.Header,
+			reviewHelpStyle.Render(fmt.Sprintf("+%d -%d", h.Added, h.Removed))))
+	}
+
+	b.WriteString("\n" + reviewBoxStyle.Render(m.preview.View()) + "\n")
+	b.WriteString(reviewHelpStyle.Render(fmt.Sprintf("%d selected • space toggle • f toggle file • a toggle all • enter stage and continue • q abort", count)))
+	return b.String()
+}
+
+// PickHunks shows the unstaged hunks in a picker, similar to git add -p,
+// and stages the selected ones. It returns false when the user aborted or
+// selected nothing.
+func (r *Repo) PickHunks(ctx context.Context) (bool, error) {
+	diff, err := r.Diff()
+	if err != nil {
+		return false, err
+	}
+	files := ParseDiff(diff)
+	if len(files) == 0 {
+		return false, fmt.Errorf("no unstaged changes to pick from")
+	}
+
+	p := tea.NewProgram(newHunkPickerModel(files), tea.WithAltScreen(), tea.WithContext(ctx))
+	final, err := p.Run()
+	if err != nil {
+		return false, err
+	}
+
+	m := final.(hunkPickerModel)
+	if !m.accepted {
+		return false, nil
+	}
+
+	patch := BuildPatch(files, m.selected)
+	if patch == "" {
+		return false, nil
+	}
+	return true, r.ApplyToIndex(patch)
+}
+
+// ApplyToIndex stages a patch without touching the working tree
+func (r *Repo) ApplyToIndex(patch string) error {
+	_, err := r.GitInput(patch, "apply", "--cached", "--recount", "-")
+	return err
+}
@@ -0,0 +1,202 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Identity is the author used for the repositories under Dir, such as a
+// work email for ~/work
+type Identity struct {
+	Dir        string `yaml:"dir"`
+	Name       string `yaml:"name"`
+	Email      string `yaml:"email"`
+	SigningKey string `yaml:"signing_key"`
+	Sign       bool   `yaml:"sign"`
+}
+
+// Teammate is someone who can be added as a co-author
+type Teammate struct {
+	Name  string `yaml:"name"`
+	Email string `yaml:"email"`
+	Alias string `yaml:"alias"` // short name for --co-author
+}
+
+// Trailer returns the Co-authored-by trailer of the teammate
+func (t Teammate) Trailer() Footer {
+	return Footer{Token: "Co-authored-by", Value: fmt.Sprintf("%s <%s>", t.Name, t.Email)}
+}
+
+// IdentityError blocks pushing commits authored with another email than
+// the one of the identity of the directory
+type IdentityError struct {
+	Expected string
+	Commits  []string // "<hash> <email>"
+}
+
+func (e *IdentityError) Error() string {
+	return fmt.Sprintf("%d unpushed commits are not authored with %s: %s", len(e.Commits), e.Expected, strings.Join(e.Commits, ", "))
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~"); ok && (rest == "" || rest[0] == '/') {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// IdentityFor returns the identity whose directory holds path, the deepest
+// one when several do
+func IdentityFor(identities []Identity, path string) (Identity, bool) {
+	var best Identity
+	found := false
+	for _, id := range identities {
+		dir := filepath.Clean(expandHome(id.Dir))
+		if path != dir && !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			continue
+		}
+		if !found || len(dir) > len(filepath.Clean(expandHome(best.Dir))) {
+			best, found = id, true
+		}
+	}
+	return best, found
+}
+
+// FindTeammate looks a teammate up by alias, name or email. "Name <email>"
+// is accepted as is for people who are not in the list.
+func FindTeammate(teammates []Teammate, who string) (Teammate, bool) {
+	who = strings.TrimSpace(who)
+	for _, t := range teammates {
+		if strings.EqualFold(who, t.Alias) || strings.EqualFold(who, t.Name) || strings.EqualFold(who, t.Email) {
+			return t, true
+		}
+	}
+	if name, email, ok := strings.Cut(who, "<"); ok && strings.HasSuffix(email, ">") {
+		return Teammate{Name: strings.TrimSpace(name), Email: strings.TrimSuffix(email, ">")}, true
+	}
+	return Teammate{}, false
+}
+
+// AddCoAuthors appends a Co-authored-by trailer per teammate the message
+// does not credit yet, in the trailer block when the message has one
+func AddCoAuthors(message string, teammates []Teammate) string {
+	message = strings.TrimSpace(message)
+	lines := strings.Split(message, "\n")
+
+	var missing []string
+	for _, t := range teammates {
+		trailer := t.Trailer()
+		credited := false
+		for _, line := range lines {
+			if strings.EqualFold(strings.TrimSpace(line), trailer.Token+": "+trailer.Value) {
+				credited = true
+				break
+			}
+		}
+		if !credited {
+			missing = append(missing, trailer.Token+": "+trailer.Value)
+		}
+	}
+	if len(missing) == 0 {
+		return message
+	}
+
+	if _, footers := parseBody(lines[1:]); footers != nil {
+		return message + "\n" + strings.Join(missing, "\n")
+	}
+	return message + "\n\n" + strings.Join(missing, "\n")
+}
+
+// Identity returns the identity configured for the directory of the
+// repository, if any
+func (r *Repo) Identity() (Identity, bool) {
+	config, err := LoadConfig()
+	if err != nil {
+		return Identity{}, false
+	}
+	return IdentityFor(config.Identities, r.Path)
+}
+
+// commitSettings returns the git options placed before and after the
+// commit subcommand to apply the identity and the signing settings. sign
+// forces signing, Options.Sign.
+func (r *Repo) commitSettings(sign bool) (global, args []string) {
+	config, _ := LoadConfig()
+	id, ok := IdentityFor(config.Identities, r.Path)
+
+	if ok && id.Name != "" {
+		global = append(global, "-c", "user.name="+id.Name)
+	}
+	if ok && id.Email != "" {
+		global = append(global, "-c", "user.email="+id.Email)
+	}
+
+	key := config.SigningKey
+	if ok && id.SigningKey != "" {
+		key = id.SigningKey
+	}
+	if sign || config.Sign || (ok && id.Sign) {
+		if config.SigningFormat != "" {
+			global = append(global, "-c", "gpg.format="+config.SigningFormat)
+		}
+		if key != "" {
+			global = append(global, "-c", "user.signingkey="+expandHome(key))
+		}
+		args = append(args, "-S")
+	}
+	return global, args
+}
+
+// CheckIdentity verifies that the commits the user made and did not push
+// yet are authored with the email of the identity of the directory. Those
+// are the commits after the upstream of the branch, or the ones on no
+// remote branch when it has none; without any remote-tracking branch there
+// is nothing to compare with and the check is skipped. Commits someone else
+// committed, such as those of a teammate's branch, are left out. It returns
+// an *IdentityError when some are not.
+func (r *Repo) CheckIdentity() error {
+	id, ok := r.Identity()
+	if !ok || id.Email == "" {
+		return nil
+	}
+
+	revs := []string{"@{upstream}..HEAD"}
+	if _, err := r.Git("rev-parse", "--verify", "--quiet", "@{upstream}"); err != nil {
+		if refs, _ := r.gitLines("for-each-ref", "--count=1", "refs/remotes"); len(refs) == 0 {
+			return nil
+		}
+		revs = []string{"HEAD", "--not", "--remotes"}
+	}
+	lines, err := r.gitLines(append([]string{"log", "--format=%h %ae %ce"}, revs...)...)
+	if err != nil {
+		return err
+	}
+
+	// The user commits with the identity, or with their usual email when
+	// the commit was made without ai_commit
+	mine := map[string]bool{strings.ToLower(id.Email): true}
+	if ident, err := r.Git("var", "GIT_COMMITTER_IDENT"); err == nil {
+		if start, end := strings.Index(ident, "<"), strings.Index(ident, ">"); start >= 0 && end > start {
+			mine[strings.ToLower(ident[start+1:end])] = true
+		}
+	}
+
+	var wrong []string
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !mine[strings.ToLower(fields[2])] {
+			continue
+		}
+		if !strings.EqualFold(fields[1], id.Email) {
+			wrong = append(wrong, fields[0]+" "+fields[1])
+		}
+	}
+	if len(wrong) > 0 {
+		return &IdentityError{Expected: id.Email, Commits: wrong}
+	}
+	return nil
+}
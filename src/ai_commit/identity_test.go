@@ -0,0 +1,151 @@
+package src_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestIdentityFor(t *testing.T) {
+	identities := []src.Identity{
+		{Dir: "/home/me", Email: "me@example.com"},
+		{Dir: "/home/me/work", Email: "me@corp.example"},
+	}
+
+	tests := []struct {
+		path  string
+		email string
+		found bool
+	}{
+		{"/home/me/work/api", "me@corp.example", true},
+		{"/home/me/work", "me@corp.example", true},
+		{"/home/me/workshop", "me@example.com", true},
+		{"/srv/repo", "", false},
+	}
+
+	for _, tt := range tests {
+		id, ok := src.IdentityFor(identities, tt.path)
+		if ok != tt.found || id.Email != tt.email {
+			t.Errorf("IdentityFor(%q) = %q, %v, want %q, %v", tt.path, id.Email, ok, tt.email, tt.found)
+		}
+	}
+}
+
+func TestAddCoAuthors(t *testing.T) {
+	ada := src.Teammate{Name: "Ada Lovelace", Email: "ada@example.com"}
+	alan := src.Teammate{Name: "Alan Turing", Email: "alan@example.com"}
+
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			"subject only",
+			"feat: add login",
+			"feat: add login\n\nCo-authored-by: Ada Lovelace <ada@example.com>\nCo-authored-by: Alan Turing <alan@example.com>",
+		},
+		{
+			"body",
+			"feat: add login\n\nUsers can sign in now.",
+			"feat: add login\n\nUsers can sign in now.\n\nCo-authored-by: Ada Lovelace <ada@example.com>\nCo-authored-by: Alan Turing <alan@example.com>",
+		},
+		{
+			"existing trailers",
+			"fix: close rows\n\nRefs: #12\nCo-authored-by: Ada Lovelace <ada@example.com>",
+			"fix: close rows\n\nRefs: #12\nCo-authored-by: Ada Lovelace <ada@example.com>\nCo-authored-by: Alan Turing <alan@example.com>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := src.AddCoAuthors(tt.message, []src.Teammate{ada, alan}); got != tt.want {
+				t.Errorf("AddCoAuthors() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckIdentity(t *testing.T) {
+	other := []string{"GIT_AUTHOR_EMAIL=ada@example.com", "GIT_COMMITTER_EMAIL=ada@example.com"}
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, dir string)
+		wrong int
+	}{
+		{
+			name:  "no remote",
+			setup: func(t *testing.T, dir string) {},
+		},
+		{
+			name: "never fetched",
+			setup: func(t *testing.T, dir string) {
+				git(t, dir, "remote", "add", "origin", t.TempDir())
+			},
+		},
+		{
+			name: "after the upstream",
+			setup: func(t *testing.T, dir string) {
+				pushMain(t, dir, "-u")
+				commitFile(t, dir, "a.txt", "a\n", "add a")
+			},
+			wrong: 1,
+		},
+		{
+			name: "not on a remote",
+			setup: func(t *testing.T, dir string) {
+				pushMain(t, dir)
+				git(t, dir, "checkout", "-q", "-b", "feature")
+				commitFile(t, dir, "a.txt", "a\n", "add a")
+				commitFile(t, dir, "b.txt", "b\n", "add b")
+			},
+			wrong: 2,
+		},
+		{
+			name: "committed by someone else",
+			setup: func(t *testing.T, dir string) {
+				pushMain(t, dir, "-u")
+				commitFile(t, dir, "a.txt", "a\n", "add a", other...)
+			},
+		},
+		{
+			name: "authored with the identity",
+			setup: func(t *testing.T, dir string) {
+				pushMain(t, dir, "-u")
+				commitFile(t, dir, "a.txt", "a\n", "add a", "GIT_AUTHOR_EMAIL=me@corp.example")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			writeFile(t, os.Getenv("XDG_CONFIG_HOME"), "zvezda/config.yml",
+				"identities:\n  - dir: "+repo.Path+"\n    email: me@corp.example\n")
+			tt.setup(t, repo.Path)
+
+			err := repo.CheckIdentity()
+			var idErr *src.IdentityError
+			if tt.wrong == 0 {
+				if err != nil {
+					t.Errorf("CheckIdentity() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.As(err, &idErr) || len(idErr.Commits) != tt.wrong {
+				t.Errorf("CheckIdentity() = %v, want %d commits", err, tt.wrong)
+			}
+		})
+	}
+}
+
+// pushMain pushes main to a new bare origin
+func pushMain(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	remote := t.TempDir()
+	git(t, remote, "init", "-q", "--bare", "-b", "main")
+	git(t, dir, "remote", "add", "origin", remote)
+	git(t, dir, append(append([]string{"push", "-q"}, args...), "origin", "main")...)
+}
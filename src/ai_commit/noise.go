@@ -0,0 +1,387 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Kinds of files whose diffs are collapsed to a line in the prompt
+const (
+	NoiseLockfile  = "lockfile"
+	NoiseVendored  = "vendored"
+	NoiseGenerated = "generated"
+	NoiseMinified  = "minified"
+	NoiseBinary    = "binary"
+	NoisePattern   = "ignored" // matched one of NoiseRules.Patterns
+)
+
+// Lockfiles are the dependency lock files of the common package managers
+var Lockfiles = []string{
+	"go.sum", "go.work.sum", "package-lock.json", "npm-shrinkwrap.json",
+	"yarn.lock", "pnpm-lock.yaml", "bun.lockb", "Cargo.lock", "poetry.lock",
+	"Pipfile.lock", "uv.lock", "pdm.lock", "Gemfile.lock", "composer.lock",
+	"mix.lock", "pubspec.lock", "Podfile.lock", "Package.resolved",
+	"flake.lock", "gradle.lockfile", "packages.lock.json",
+}
+
+// VendorDirs hold third party code copied into the repository
+var VendorDirs = []string{"vendor", "node_modules", "third_party", "bower_components", "Pods"}
+
+// generatedSuffixes are file names code generators commonly produce
+var generatedSuffixes = []string{".pb.go", ".pb.gw.go", "_generated.go", ".gen.go", ".g.dart", ".freezed.dart", "_pb2.py", ".designer.cs"}
+
+// minifiedSuffixes are bundled or minified assets
+var minifiedSuffixes = []string{".min.js", ".min.css", ".min.mjs", ".js.map", ".css.map", ".bundle.js"}
+
+// commentPrefixes start the comment lines of a file header
+var commentPrefixes = []string{"//", "/*", "*", "#", "--", "<!--", ";", "%"}
+
+// minifiedLineLength is the length from which an added line is considered
+// minified rather than written by hand
+const minifiedLineLength = 1000
+
+// maxNoiseLines bounds how many noise summaries go into the prompt
+const maxNoiseLines = 10
+
+// headerLines bounds how far into a file generated code markers are looked
+// for
+const headerLines = 30
+
+var (
+	// https://go.dev/s/generatedcode, and the @generated marker other tools
+	// put in the comments a file opens with
+	goGeneratedRegex = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+	atGeneratedRegex = regexp.MustCompile(`@generated\b`)
+	goSumRegex       = regexp.MustCompile(`^[+-](\S+) (v\S+?)(?:/go\.mod)? h1:`)
+	lockVersionRegex = regexp.MustCompile(`^[+-]\s*"?version"?\s*[:=]?\s*"?v?\d`)
+)
+
+// NoiseRules adjusts which files are noise, read from noise: in the user
+// config and in .zvezda.yml. Patterns and Keep are globs, matched against
+// the file name when they have no slash and the whole path otherwise.
+type NoiseRules struct {
+	Patterns []string `yaml:"patterns"` // always collapsed
+	Keep     []string `yaml:"keep"`     // never collapsed, even when detected as noise
+}
+
+func matchNoiseGlob(pattern, file string) bool {
+	if !strings.Contains(pattern, "/") {
+		return MatchGlob(pattern, path.Base(file))
+	}
+	return MatchGlob(strings.TrimPrefix(pattern, "/"), file)
+}
+
+// Classify returns the kind of noise the file change is, or "" when its
+// diff is worth showing. head is the start of the file content, used to
+// find generated code markers, and may be "": the first hunk is used
+// instead when it starts the file.
+func (n NoiseRules) Classify(f FileDiff, head string) string {
+	file := f.Path()
+	for _, pattern := range n.Keep {
+		if matchNoiseGlob(pattern, file) {
+			return ""
+		}
+	}
+	for _, pattern := range n.Patterns {
+		if matchNoiseGlob(pattern, file) {
+			return NoisePattern
+		}
+	}
+
+	base := path.Base(file)
+	switch {
+	case f.Binary:
+		return NoiseBinary
+	case contains(Lockfiles, base):
+		return NoiseLockfile
+	case vendorRoot(file) != "":
+		return NoiseVendored
+	case hasAnySuffix(base, minifiedSuffixes):
+		return NoiseMinified
+	case hasAnySuffix(base, generatedSuffixes):
+		return NoiseGenerated
+	}
+
+	if head == "" {
+		head = hunkHead(f)
+	}
+	if generatedHeader(head) {
+		return NoiseGenerated
+	}
+
+	for _, h := range f.Hunks {
+		for _, line := range h.Lines {
+			if strings.HasPrefix(line, "+") && len(line) > minifiedLineLength {
+				return NoiseMinified
+			}
+		}
+	}
+	return ""
+}
+
+// generatedHeader reports whether the start of a file carries a generated
+// code marker: the Go one on a line before the package clause, or
+// @generated in the comments before the first line of code
+func generatedHeader(head string) bool {
+	lines := strings.Split(head, "\n")
+	for _, line := range lines[:min(len(lines), headerLines)] {
+		line = strings.TrimRight(line, "\r")
+		if goGeneratedRegex.MatchString(line) {
+			return true
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case hasAnyPrefix(trimmed, commentPrefixes):
+			if atGeneratedRegex.MatchString(trimmed) {
+				return true
+			}
+		default:
+			// The package clause or the first line of code ends the header
+			return false
+		}
+	}
+	return false
+}
+
+// hunkHead returns the start of the file after the changes when the first
+// hunk shows it, "" otherwise
+func hunkHead(f FileDiff) string {
+	if len(f.Hunks) == 0 {
+		return ""
+	}
+	h := f.Hunks[0]
+	if m := hunkStartRegex.FindStringSubmatch(h.Header); m == nil || (m[1] != "1" && m[1] != "0") {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range h.Lines {
+		if line != "" && (line[0] == ' ' || line[0] == '+') {
+			b.WriteString(line[1:] + "\n")
+		}
+	}
+	return b.String()
+}
+
+// Filter splits a diff into the part worth showing the model and one line
+// summaries of the noise, see Classify. head returns the start of a file,
+// it may be nil.
+func (n NoiseRules) Filter(diff string, head func(file string) string) (string, []string) {
+	var kept strings.Builder
+	var noise []string
+	// Vendored files are counted per vendor directory, on the line of the
+	// first one
+	vendored := make(map[string][]FileDiff)
+	vendoredLine := make(map[string]int)
+
+	for _, chunk := range splitDiff(diff) {
+		files := ParseDiff(chunk)
+		if len(files) != 1 {
+			kept.WriteString(chunk)
+			continue
+		}
+		f := files[0]
+
+		start := ""
+		if head != nil && f.Status != "deleted" {
+			start = head(f.Path())
+		}
+		switch kind := n.Classify(f, start); kind {
+		case "":
+			kept.WriteString(chunk)
+		case NoiseLockfile:
+			noise = append(noise, "lockfile: "+DescribeLockfile(f))
+		case NoiseVendored:
+			root := vendorRoot(f.Path())
+			if _, ok := vendoredLine[root]; !ok {
+				vendoredLine[root] = len(noise)
+				noise = append(noise, "")
+			}
+			vendored[root] = append(vendored[root], f)
+		default:
+			noise = append(noise, kind+": "+f.Describe())
+		}
+	}
+
+	for root, i := range vendoredLine {
+		noise[i] = "vendored: " + describeVendored(root, vendored[root])
+	}
+
+	if len(noise) > maxNoiseLines {
+		more := len(noise) - maxNoiseLines + 1
+		noise = append(noise[:maxNoiseLines-1], fmt.Sprintf("... %d more noise files", more))
+	}
+	return kept.String(), noise
+}
+
+// splitDiff cuts a unified diff into one chunk per file
+func splitDiff(diff string) []string {
+	var chunks []string
+	var current strings.Builder
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") && current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// vendorRoot returns the path up to the vendored directory holding file,
+// or "" when it is not vendored
+func vendorRoot(file string) string {
+	parts := strings.Split(file, "/")
+	for i, part := range parts[:len(parts)-1] {
+		if contains(VendorDirs, part) {
+			return strings.Join(parts[:i+1], "/") + "/"
+		}
+	}
+	return ""
+}
+
+func hasAnySuffix(s string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func describeVendored(root string, files []FileDiff) string {
+	added, removed := 0, 0
+	for _, f := range files {
+		added += f.Added()
+		removed += f.Removed()
+	}
+	return fmt.Sprintf("%s changed under %s (+%d -%d)", plural(len(files), "file", "files"), root, added, removed)
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+// DescribeLockfile summarizes the change of a lock file by the number of
+// dependencies updated, added and removed, such as "updated 14
+// dependencies in go.sum"
+func DescribeLockfile(f FileDiff) string {
+	var updated, added, removed int
+	if path.Base(f.Path()) == "go.sum" || path.Base(f.Path()) == "go.work.sum" {
+		updated, added, removed = goSumChanges(f)
+	} else {
+		// Most lock files have a version line per package
+		for _, h := range f.Hunks {
+			for _, line := range h.Lines {
+				if !lockVersionRegex.MatchString(line) {
+					continue
+				}
+				if line[0] == '+' {
+					added++
+				} else {
+					removed++
+				}
+			}
+		}
+		updated = min(added, removed)
+		added -= updated
+		removed -= updated
+	}
+
+	var parts []string
+	if updated > 0 {
+		parts = append(parts, "updated "+plural(updated, "dependency", "dependencies"))
+	}
+	if added > 0 {
+		parts = append(parts, "added "+plural(added, "dependency", "dependencies"))
+	}
+	if removed > 0 {
+		parts = append(parts, "removed "+plural(removed, "dependency", "dependencies"))
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("%s %s (+%d -%d)", f.Status, f.Path(), f.Added(), f.Removed())
+	}
+	return strings.Join(parts, ", ") + " in " + f.Path()
+}
+
+// goSumChanges counts the modules whose version changed, appeared or
+// disappeared in a go.sum diff
+func goSumChanges(f FileDiff) (updated, added, removed int) {
+	plus := make(map[string]bool)
+	minus := make(map[string]bool)
+	for _, h := range f.Hunks {
+		for _, line := range h.Lines {
+			m := goSumRegex.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			if line[0] == '+' {
+				plus[m[1]] = true
+			} else {
+				minus[m[1]] = true
+			}
+		}
+	}
+	for module := range plus {
+		if minus[module] {
+			updated++
+		} else {
+			added++
+		}
+	}
+	for module := range minus {
+		if !plus[module] {
+			removed++
+		}
+	}
+	return updated, added, removed
+}
+
+// NoiseRules returns the noise rules of the user config and the conventions
+// of the repository combined
+func (r *Repo) NoiseRules() NoiseRules {
+	var rules NoiseRules
+	if config, err := LoadConfig(); err == nil {
+		rules = config.Noise
+	}
+	if conventions, err := LoadConventions(r.Path); err == nil {
+		rules.Patterns = append(rules.Patterns, conventions.Noise.Patterns...)
+		rules.Keep = append(rules.Keep, conventions.Noise.Keep...)
+	}
+	return rules
+}
+
+// fileHead returns the first bytes of a changed file, enough to find a
+// generated code marker
+func (r *Repo) fileHead(c Changes, file string) string {
+	if c.Target == "" {
+		f, err := os.Open(r.Abs(file))
+		if err != nil {
+			return ""
+		}
+		defer f.Close()
+		data, _ := io.ReadAll(io.LimitReader(f, 1024))
+		return string(data)
+	}
+	data := r.fileAfter(c, file)
+	return string(data[:min(len(data), 1024)])
+}
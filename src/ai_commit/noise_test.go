@@ -0,0 +1,112 @@
+package src_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func fileDiff(path string, lines ...string) string {
+	return "diff --git a/" + path + " b/" + path + "\n" +
+		"--- a/" + path + "\n+++ b/" + path + "\n" +
+		"@@ -1,2 +1,2 @@\n" + strings.Join(lines, "\n") + "\n"
+}
+
+func TestNoiseClassify(t *testing.T) {
+	rules := src.NoiseRules{Patterns: []string{"*.snap"}, Keep: []string{"vendor/ours/**"}}
+
+	tests := []struct {
+		name string
+		diff string
+		head string
+		want string
+	}{
+		{"code", fileDiff("main.go", "-a", "+b"), "", ""},
+		{"lockfile", fileDiff("web/yarn.lock", "-a", "+b"), "", src.NoiseLockfile},
+		{"vendored", fileDiff("vendor/golang.org/x/net/http.go", "-a", "+b"), "", src.NoiseVendored},
+		{"kept vendored", fileDiff("vendor/ours/lib.go", "-a", "+b"), "", ""},
+		{"generated suffix", fileDiff("api/api.pb.go", "-a", "+b"), "", src.NoiseGenerated},
+		{"generated marker", fileDiff("enum.go", "-a", "+b"), "// Code generated by stringer. DO NOT EDIT.\n\npackage x\n", src.NoiseGenerated},
+		{"hand written _string.go", fileDiff("format/to_string.go", "-a", "+b"), "package format\n", ""},
+		{"marker after package", fileDiff("gen.go", "-a", "+b"), "package gen\n\n// Code generated by x. DO NOT EDIT.\n", ""},
+		{"marker in context", fileDiff("gen.go", " const header = \"// Code generated by x. DO NOT EDIT.\"", "-a", "+b"), "package gen\n", ""},
+		{"@generated header", fileDiff("Schema.java", "-a", "+b"), "/*\n * @generated by protoc\n */\npackage x;\n", src.NoiseGenerated},
+		{"@generated in code", fileDiff("tags.py", "-a", "+b"), "import re\n\nTAG = \"@generated\"\n", ""},
+		{"marker in first hunk", fileDiff("enum.go", "+// Code generated by stringer. DO NOT EDIT.", "+", " package x"), "", src.NoiseGenerated},
+		{"marker in later hunk", strings.Replace(fileDiff("enum.go", "+// Code generated by stringer. DO NOT EDIT."), "+1,2", "+40,2", 1), "", ""},
+		{"minified suffix", fileDiff("static/app.min.js", "-a", "+b"), "", src.NoiseMinified},
+		{"minified line", fileDiff("static/app.js", "+"+strings.Repeat("x", 1200)), "", src.NoiseMinified},
+		{"pattern", fileDiff("ui/__snapshots__/button.snap", "-a", "+b"), "", src.NoisePattern},
+		{"binary", "diff --git a/logo.png b/logo.png\nBinary files a/logo.png and b/logo.png differ\n", "", src.NoiseBinary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := src.ParseDiff(tt.diff)
+			if len(files) != 1 {
+				t.Fatalf("ParseDiff() returned %d files", len(files))
+			}
+			if got := rules.Classify(files[0], tt.head); got != tt.want {
+				t.Errorf("Classify() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeLockfile(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want string
+	}{
+		{
+			"go.sum",
+			fileDiff("go.sum",
+				"-golang.org/x/net v0.1.0 h1:aaa=",
+				"-golang.org/x/net v0.1.0/go.mod h1:bbb=",
+				"+golang.org/x/net v0.2.0 h1:ccc=",
+				"+golang.org/x/net v0.2.0/go.mod h1:ddd=",
+				"+golang.org/x/text v0.3.0 h1:eee=",
+				"-gopkg.in/yaml.v2 v2.4.0 h1:fff="),
+			"updated 1 dependency, added 1 dependency, removed 1 dependency in go.sum",
+		},
+		{
+			"Cargo.lock",
+			fileDiff("Cargo.lock", `-version = "1.0.1"`, `+version = "1.0.2"`, `-version = "0.4.0"`, `+version = "0.5.0"`),
+			"updated 2 dependencies in Cargo.lock",
+		},
+		{
+			"no versions",
+			fileDiff("yarn.lock", "-  integrity sha512-a", "+  integrity sha512-b"),
+			"modified yarn.lock (+1 -1)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := src.DescribeLockfile(src.ParseDiff(tt.diff)[0]); got != tt.want {
+				t.Errorf("DescribeLockfile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoiseFilter(t *testing.T) {
+	diff := fileDiff("main.go", "-a", "+b") +
+		fileDiff("vendor/a/a.go", "-a", "+b") +
+		fileDiff("go.sum", "+example.com/m v1.0.0 h1:x=") +
+		fileDiff("vendor/b/b.go", "+c")
+
+	kept, noise := src.NoiseRules{}.Filter(diff, nil)
+	if kept != fileDiff("main.go", "-a", "+b") {
+		t.Errorf("Filter() kept\n%s", kept)
+	}
+	want := []string{
+		"vendored: 2 files changed under vendor/ (+2 -1)",
+		"lockfile: added 1 dependency in go.sum",
+	}
+	if strings.Join(noise, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Filter() noise = %q, want %q", noise, want)
+	}
+}
@@ -0,0 +1,88 @@
+package src
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OllamaProvider talks to the Ollama /api/generate endpoint
+type OllamaProvider struct {
+	Endpoint string
+	Model    string
+}
+
+func (p *OllamaProvider) Name() string {
+	return "ollama"
+}
+
+func (p *OllamaProvider) Generate(ctx context.Context, req Request) (string, error) {
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"model":  p.Model,
+		"prompt": req.Prompt,
+		"stream": true,
+		"options": map[string]interface{}{
+			"temperature": req.Temperature,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint+"/api/generate", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{Provider: p.Name(), Status: resp.Status, Code: resp.StatusCode}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var builder strings.Builder
+
+	// Each line of the stream is a JSON object, the last one has done set
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk struct {
+			Response string `json:"response"`
+			Done     bool   `json:"done"`
+			Error    string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return builder.String(), fmt.Errorf("malformed Ollama stream line %q: %w", line, err)
+		}
+		if chunk.Error != "" {
+			return builder.String(), &StreamError{Provider: p.Name(), Message: chunk.Error}
+		}
+
+		if req.OnToken != nil {
+			req.OnToken(chunk.Response)
+		}
+		builder.WriteString(chunk.Response)
+
+		if chunk.Done {
+			return builder.String(), nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return builder.String(), err
+	}
+	return builder.String(), fmt.Errorf("ollama stream ended before completion")
+}
@@ -0,0 +1,119 @@
+package src
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAIProvider talks to any server exposing the OpenAI compatible
+// /v1/chat/completions endpoint (llama.cpp server, vLLM, LM Studio)
+type OpenAIProvider struct {
+	Endpoint string
+	Model    string
+	APIKey   string
+}
+
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+func (p *OpenAIProvider) url() string {
+	if strings.HasSuffix(p.Endpoint, "/chat/completions") {
+		return p.Endpoint
+	}
+	if strings.HasSuffix(p.Endpoint, "/v1") {
+		return p.Endpoint + "/chat/completions"
+	}
+	return p.Endpoint + "/v1/chat/completions"
+}
+
+func (p *OpenAIProvider) Generate(ctx context.Context, req Request) (string, error) {
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"model": p.Model,
+		"messages": []map[string]string{
+			{"role": "user", "content": req.Prompt},
+		},
+		"temperature": req.Temperature,
+		"stream":      true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url(), bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to %s: %w", p.Endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{Provider: p.Endpoint, Status: resp.Status, Code: resp.StatusCode}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var builder strings.Builder
+
+	// The response is a server-sent event stream of "data: {...}" lines,
+	// ending with "data: [DONE]" after a choice with a finish_reason
+	finished := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return builder.String(), nil
+		}
+
+		var chunk struct {
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return builder.String(), fmt.Errorf("malformed stream event %q: %w", data, err)
+		}
+		if chunk.Error != nil {
+			return builder.String(), &StreamError{Provider: p.Endpoint, Message: chunk.Error.Message}
+		}
+		if len(chunk.Choices) > 0 {
+			text := chunk.Choices[0].Delta.Content
+			if req.OnToken != nil {
+				req.OnToken(text)
+			}
+			builder.WriteString(text)
+			if reason := chunk.Choices[0].FinishReason; reason != nil && *reason != "" {
+				finished = true
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return builder.String(), err
+	}
+	if !finished {
+		return builder.String(), fmt.Errorf("openai stream ended before completion")
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,28 @@
+package src
+
+// Options are the settings of a single run, given on the command line,
+// passed to the functions generating and committing messages. Settings of
+// the repository itself come from the config and the conventions instead.
+type Options struct {
+	// StagedOnly commits the index as it is, AddCommitPush stages nothing
+	StagedOnly bool
+
+	// Template names the prompt template, see TemplateName
+	Template string
+
+	// LearnStyle adds examples and statistics from the commit history to
+	// the prompt, see Style
+	LearnStyle bool
+
+	// NoCache makes GenerateCommitMessage ask the model even when a message
+	// was cached for the same changes, see CacheKey
+	NoCache bool
+
+	// Sign signs the commits, on top of the sign settings of the config and
+	// the identity, see commitSettings
+	Sign bool
+
+	// CoAuthors are credited with Co-authored-by trailers in the messages
+	// of Commit, see AddCoAuthors
+	CoAuthors []Teammate
+}
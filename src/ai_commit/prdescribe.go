@@ -0,0 +1,198 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PRDescription is a generated pull request title and body
+type PRDescription struct {
+	Title    string
+	Body     string
+	Fallback bool // built from the commit list without the model
+}
+
+// PRRange is the part of history a pull request would contain
+type PRRange struct {
+	Branch    string
+	Base      string // branch the pull request targets
+	MergeBase string
+	Commits   []string // "<hash> <subject>", oldest first
+	Diff      string
+	Stat      string
+	Noise     NoiseRules // files summarized in a line, see NoiseRules.Filter
+}
+
+// DefaultBranch returns the branch the remote HEAD points to, falling back
+// to a local main or master branch
+func (r *Repo) DefaultBranch() string {
+	out, err := r.Git("symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	if err == nil && strings.TrimSpace(out) != "" {
+		return strings.TrimSpace(out)
+	}
+	for _, branch := range []string{"main", "master"} {
+		if _, err := r.Git("rev-parse", "--verify", "--quiet", branch); err == nil {
+			return branch
+		}
+	}
+	return "main"
+}
+
+// BranchRange collects the commits and the combined diff of the current
+// branch since its merge-base with base
+func (r *Repo) BranchRange(base string) (PRRange, error) {
+	pr := PRRange{Base: base, Noise: r.NoiseRules()}
+	pr.Branch, _ = r.Branch()
+
+	out, err := r.Git("merge-base", base, "HEAD")
+	if err != nil {
+		return pr, fmt.Errorf("no merge-base between %s and HEAD", base)
+	}
+	pr.MergeBase = strings.TrimSpace(out)
+
+	pr.Commits, err = r.gitLines("log", "--no-merges", "--reverse", "--format=%h %s", pr.MergeBase+"..HEAD")
+	if err != nil {
+		return pr, err
+	}
+	if len(pr.Commits) == 0 {
+		return pr, fmt.Errorf("the branch has no commits on top of %s", base)
+	}
+
+	pr.Diff, _ = r.Git("diff", pr.MergeBase+"..HEAD")
+	out, _ = r.Git("diff", "--stat", pr.MergeBase+"..HEAD")
+	pr.Stat = strings.TrimSpace(out)
+	return pr, nil
+}
+
+// PRPrompt asks for a title and a body with Summary, Changes and Testing
+// sections, given the commits and the diff summarized to budget tokens
+func PRPrompt(r PRRange, budget int) string {
+	var commits strings.Builder
+	for _, c := range r.Commits {
+		commits.WriteString("  - " + c + "\n")
+	}
+
+	kept, noise := r.Noise.Filter(r.Diff, nil)
+	diff := SummarizeDiff(kept, budget)
+	diff.Noise = noise
+
+	return fmt.Sprintf(`You are an AI Git assistant. Write a pull request description for the
+changes of branch %s, which will be merged into %s.
+
+Answer in exactly this format:
+Title: <short imperative title, under 72 characters>
+
+## Summary
+<2 to 4 sentences on what the pull request does and why>
+
+## Changes
+- <one bullet per notable change>
+
+## Testing
+- <how the changes were or should be tested>
+
+ONLY return the description, nothing else.
+
+Commits:
+%s
+Files changed:
+%s
+
+%s`, r.Branch, r.Base, commits.String(), r.Stat, formatDiffSummary("Combined Diff", diff))
+}
+
+// ParsePRDescription splits a model answer into a title and a body
+func ParsePRDescription(resp string) (PRDescription, bool) {
+	lines := strings.Split(strings.TrimSpace(stripFences(resp)), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		title, ok := strings.CutPrefix(line, "Title:")
+		if !ok {
+			title, ok = strings.CutPrefix(line, "# ")
+		}
+		if !ok {
+			continue
+		}
+		body := strings.TrimSpace(strings.Join(lines[i+1:], "\n"))
+		if !strings.Contains(body, "## ") {
+			return PRDescription{}, false
+		}
+		return PRDescription{Title: unquote(strings.TrimSpace(title)), Body: body}, true
+	}
+	return PRDescription{}, false
+}
+
+// stripFences removes a code fence around the whole answer
+func stripFences(resp string) string {
+	resp = strings.TrimSpace(resp)
+	if !strings.HasPrefix(resp, "```") {
+		return resp
+	}
+	lines := strings.Split(resp, "\n")
+	lines = lines[1:]
+	if len(lines) > 0 && strings.HasPrefix(strings.TrimSpace(lines[len(lines)-1]), "```") {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// templatePR describes the range from its commit subjects alone
+func templatePR(r PRRange) PRDescription {
+	subject := func(c string) string {
+		_, s, _ := strings.Cut(c, " ")
+		return s
+	}
+
+	branch := r.Branch
+	title := strings.NewReplacer("-", " ", "_", " ").Replace(branch[strings.LastIndex(branch, "/")+1:])
+	if len(r.Commits) == 1 {
+		title = subject(r.Commits[0])
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Summary\n\n%d commits on top of %s.\n\n## Changes\n\n", len(r.Commits), r.Base)
+	for _, c := range r.Commits {
+		b.WriteString("- " + subject(c) + "\n")
+	}
+	b.WriteString("\n## Testing\n\n- [ ] Describe how this was tested\n")
+	return PRDescription{Title: title, Body: b.String(), Fallback: true}
+}
+
+// GeneratePRDescription describes the current branch against base, the
+// default branch when empty. When the model cannot be reached or answers
+// in another format, the description is built from the commit subjects.
+func (r *Repo) GeneratePRDescription(ctx context.Context, base string, onToken func(string)) (PRDescription, error) {
+	if base == "" {
+		base = r.DefaultBranch()
+	}
+	pr, err := r.BranchRange(base)
+	if err != nil {
+		return PRDescription{}, err
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		return PRDescription{}, fmt.Errorf("failed to load config: %w", err)
+	}
+	// The heuristic provider describes commits, not pull requests
+	client, err := NewClient(config, r, Changes{}, Options{})
+	if err != nil {
+		return PRDescription{}, err
+	}
+	if client.Provider.Name() == "heuristic" {
+		return templatePR(pr), nil
+	}
+
+	resp, err := client.Ask(ctx, PRPrompt(pr, config.DiffBudget()), Stream{OnToken: onToken})
+	if ctx.Err() != nil {
+		return PRDescription{}, ctx.Err()
+	}
+	if err != nil {
+		return templatePR(pr), nil
+	}
+	if pr, ok := ParsePRDescription(resp); ok {
+		return pr, nil
+	}
+	return templatePR(pr), nil
+}
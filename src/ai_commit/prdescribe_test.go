@@ -0,0 +1,25 @@
+package src_test
+
+import (
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestParsePRDescription(t *testing.T) {
+	resp := "```markdown\nTitle: Add retry to the provider client\n\n## Summary\nRetries transient failures.\n\n## Changes\n- add backoff\n\n## Testing\n- go test ./...\n```"
+	pr, ok := src.ParsePRDescription(resp)
+	if !ok {
+		t.Fatalf("ParsePRDescription() failed on %q", resp)
+	}
+	if pr.Title != "Add retry to the provider client" {
+		t.Errorf("title = %q", pr.Title)
+	}
+	if want := "## Summary\nRetries transient failures.\n\n## Changes\n- add backoff\n\n## Testing\n- go test ./..."; pr.Body != want {
+		t.Errorf("body = %q, want %q", pr.Body, want)
+	}
+
+	if _, ok := src.ParsePRDescription("feat: add retry"); ok {
+		t.Errorf("ParsePRDescription() accepted a commit message")
+	}
+}
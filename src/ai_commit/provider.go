@@ -0,0 +1,82 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Stream receives the response text as it is generated
+type Stream struct {
+	// OnToken receives the response text as it streams in, if set
+	OnToken func(string)
+	// OnReset is called when the text streamed so far is discarded, the
+	// next attempt streams from the start. Without it the Client only
+	// passes the text of an attempt on once the attempt succeeded.
+	OnReset func()
+}
+
+// Request is a single completion request sent to a provider
+type Request struct {
+	Prompt      string
+	Temperature float64
+	Stream
+}
+
+// Provider is a backend able to turn a prompt into a commit message
+type Provider interface {
+	Name() string
+	Generate(ctx context.Context, req Request) (string, error)
+}
+
+// NewProvider builds the provider selected in the config. repo, changes and
+// opts are only used by the heuristic provider, which describes the changes
+// without reading the prompt.
+func NewProvider(config Config, repo *Repo, changes Changes, opts Options) (Provider, error) {
+	switch strings.ToLower(config.Provider) {
+	case "", "ollama":
+		return &OllamaProvider{
+			Endpoint: withDefault(config.Endpoint, "http://127.0.0.1:11434"),
+			Model:    config.Model,
+		}, nil
+	case "openai", "llamacpp", "vllm", "lmstudio":
+		return &OpenAIProvider{
+			Endpoint: withDefault(config.Endpoint, "http://127.0.0.1:8080"),
+			Model:    config.Model,
+			APIKey:   config.APIKey,
+		}, nil
+	case "heuristic", "offline":
+		return &HeuristicProvider{Repo: repo, Changes: changes, Options: opts}, nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q", config.Provider)
+	}
+}
+
+// StatusError is returned when a provider answers with a non-200 status
+type StatusError struct {
+	Provider string
+	Status   string
+	Code     int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned %s", e.Provider, e.Status)
+}
+
+// StreamError is returned when a provider reports an error inside its
+// response stream, such as an unknown model
+type StreamError struct {
+	Provider string
+	Message  string
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Provider, e.Message)
+}
+
+func withDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return strings.TrimRight(value, "/")
+}
@@ -0,0 +1,182 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Outcomes of PushBranch
+const (
+	PushPushed    = "pushed"
+	PushUpToDate  = "up-to-date"
+	PushRebased   = "rebased"   // rejected, rebased onto the remote, then pushed
+	PushRejected  = "rejected"  // the remote moved ahead and was not caught up with
+	PushProtected = "protected" // the branch is in protected_branches
+	PushNoRemote  = "no-remote"
+	PushFailed    = "failed"
+)
+
+// Values of on_rejected in the config
+const (
+	OnRejectedRebase = "rebase"
+	OnRejectedStop   = "stop"
+)
+
+// PushResult is what happened to a push of the current branch
+type PushResult struct {
+	Status      string
+	Remote      string
+	Branch      string // the branch on the remote, the upstream one if set
+	SetUpstream bool   // the branch had no upstream, it was set by this push
+	Err         error  // set unless Status is pushed, up-to-date or rebased
+}
+
+// String describes the outcome in one line
+func (p PushResult) String() string {
+	target := p.Remote + "/" + p.Branch
+	switch p.Status {
+	case PushPushed:
+		if p.SetUpstream {
+			return fmt.Sprintf("pushed %s and set it as upstream", target)
+		}
+		return "pushed " + target
+	case PushUpToDate:
+		return target + " is up to date"
+	case PushRebased:
+		return fmt.Sprintf("%s had new commits, rebased onto them and pushed", target)
+	default:
+		return fmt.Sprintf("%s: %v", p.Status, p.Err)
+	}
+}
+
+// nonFastForward reports whether a push failed because the remote branch
+// has commits the local one does not
+func nonFastForward(err error) bool {
+	var gitErr *GitError
+	if !errors.As(err, &gitErr) {
+		return false
+	}
+	for _, hint := range []string{"non-fast-forward", "fetch first", "[rejected]"} {
+		if strings.Contains(gitErr.Stderr, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProtectedBranches returns the globs of the branches nothing is pushed
+// to, from protected_branches in the user config and in .zvezda.yml
+func (r *Repo) ProtectedBranches() []string {
+	var branches []string
+	if config, err := LoadConfig(); err == nil {
+		branches = append(branches, config.ProtectedBranches...)
+	}
+	if conventions, err := LoadConventions(r.Path); err == nil {
+		branches = append(branches, conventions.ProtectedBranches...)
+	}
+	return branches
+}
+
+// pushRemote returns the remote the branch tracks and the ref it merges
+// from, such as refs/heads/main, or origin, or the only remote of the
+// repository with an empty ref, and whether the branch has an upstream.
+// A branch tracking a local one, remote ".", has no upstream to push to.
+func (r *Repo) pushRemote(branch string) (remote, merge string, tracked bool) {
+	out, err := r.Git("config", "branch."+branch+".remote")
+	if remote := strings.TrimSpace(out); err == nil && remote != "" && remote != "." {
+		if _, err := r.Git("rev-parse", "--verify", "--quiet", "@{upstream}"); err == nil {
+			merge, _ := r.Git("config", "branch."+branch+".merge")
+			return remote, strings.TrimSpace(merge), true
+		}
+	}
+	remotes, _ := r.gitLines("remote")
+	switch {
+	case contains(remotes, "origin"):
+		return "origin", "", false
+	case len(remotes) == 1:
+		return remotes[0], "", false
+	}
+	return "", "", false
+}
+
+// PushBranch pushes the current branch to its upstream, which may have
+// another name. A branch without an upstream is pushed under its own name
+// and gets one. A push rejected because the remote moved ahead is retried
+// after git pull --rebase when on_rejected is rebase, the default, and the
+// rebase is aborted if it conflicts. Nothing is pushed to branches matching
+// protected_branches.
+func (r *Repo) PushBranch() PushResult {
+	branch, err := r.Branch()
+	if err != nil {
+		return PushResult{Status: PushFailed, Err: err}
+	}
+	result := PushResult{Branch: branch}
+	if branch == "HEAD" {
+		result.Status, result.Err = PushFailed, errors.New("HEAD is detached, there is no branch to push")
+		return result
+	}
+
+	remote, merge, tracked := r.pushRemote(branch)
+	if merge == "" {
+		merge = "refs/heads/" + branch
+	}
+	result.Branch = strings.TrimPrefix(merge, "refs/heads/")
+
+	for _, pattern := range r.ProtectedBranches() {
+		for _, name := range []string{branch, result.Branch} {
+			if MatchGlob(pattern, name) {
+				result.Status = PushProtected
+				result.Err = fmt.Errorf("%s is a protected branch (%s), push it through a pull request", name, pattern)
+				return result
+			}
+		}
+	}
+
+	if remote == "" {
+		result.Status, result.Err = PushNoRemote, errors.New("the repository has no remote")
+		return result
+	}
+	result.Remote = remote
+
+	args := []string{remote, branch + ":" + merge}
+	if tracked {
+		if out, err := r.Git("rev-list", "--count", "@{upstream}..HEAD"); err == nil && strings.TrimSpace(out) == "0" {
+			result.Status = PushUpToDate
+			return result
+		}
+	} else {
+		args = append([]string{"--set-upstream"}, args...)
+		result.SetUpstream = true
+	}
+
+	err = r.Push(args...)
+	if err == nil {
+		result.Status = PushPushed
+		return result
+	}
+	if !nonFastForward(err) {
+		result.Status, result.Err = PushFailed, err
+		return result
+	}
+
+	config, _ := LoadConfig()
+	if config.OnRejected == OnRejectedStop {
+		result.Status = PushRejected
+		result.Err = fmt.Errorf("%s/%s has commits that are not here, pull them first", remote, result.Branch)
+		return result
+	}
+
+	if err := r.Pull("--rebase", "--autostash", remote, merge); err != nil {
+		r.Git("rebase", "--abort")
+		result.Status = PushRejected
+		result.Err = fmt.Errorf("rebasing onto %s/%s failed, the rebase was aborted: %w", remote, result.Branch, err)
+		return result
+	}
+	if err := r.Push(args...); err != nil {
+		result.Status, result.Err = PushFailed, err
+		return result
+	}
+	result.Status = PushRebased
+	return result
+}
@@ -0,0 +1,157 @@
+package src_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestPushResultString(t *testing.T) {
+	tests := []struct {
+		result src.PushResult
+		want   string
+	}{
+		{src.PushResult{Status: src.PushPushed, Remote: "origin", Branch: "main"}, "pushed origin/main"},
+		{src.PushResult{Status: src.PushPushed, Remote: "origin", Branch: "wip", SetUpstream: true}, "pushed origin/wip and set it as upstream"},
+		{src.PushResult{Status: src.PushUpToDate, Remote: "origin", Branch: "main"}, "origin/main is up to date"},
+		{src.PushResult{Status: src.PushRebased, Remote: "origin", Branch: "main"}, "origin/main had new commits, rebased onto them and pushed"},
+		{src.PushResult{Status: src.PushProtected, Branch: "main", Err: errors.New("main is protected")}, "protected: main is protected"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.result.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// newPushRepo creates a repository with a bare origin it was never pushed
+// to
+func newPushRepo(t *testing.T) (repo *src.Repo, remote string) {
+	t.Helper()
+	repo = newTestRepo(t)
+	remote = t.TempDir()
+	git(t, remote, "init", "-q", "--bare", "-b", "main")
+	git(t, repo.Path, "remote", "add", "origin", remote)
+	return repo, remote
+}
+
+// pushFromClone pushes a commit to main from another clone of the remote
+func pushFromClone(t *testing.T, remote, name string) {
+	t.Helper()
+	clone := filepath.Join(t.TempDir(), "clone")
+	git(t, filepath.Dir(clone), "clone", "-q", remote, clone)
+	commitFile(t, clone, name, name+"\n", "add "+name)
+	git(t, clone, "push", "-q", "origin", "main")
+}
+
+func TestPushBranch(t *testing.T) {
+	t.Run("upstream", func(t *testing.T) {
+		repo, remote := newPushRepo(t)
+
+		result := repo.PushBranch()
+		if result.Status != src.PushPushed || !result.SetUpstream {
+			t.Fatalf("PushBranch() = %v", result)
+		}
+		if got := git(t, repo.Path, "rev-parse", "--abbrev-ref", "main@{upstream}"); got != "origin/main" {
+			t.Errorf("upstream = %s, want origin/main", got)
+		}
+		if got, want := git(t, remote, "rev-parse", "main"), git(t, repo.Path, "rev-parse", "HEAD"); got != want {
+			t.Errorf("remote main = %s, want %s", got, want)
+		}
+		if result := repo.PushBranch(); result.Status != src.PushUpToDate {
+			t.Errorf("second PushBranch() = %v, want up to date", result)
+		}
+	})
+
+	t.Run("upstream with another name", func(t *testing.T) {
+		repo, remote := newPushRepo(t)
+		git(t, repo.Path, "push", "-q", "origin", "main")
+		git(t, repo.Path, "fetch", "-q", "origin")
+		git(t, repo.Path, "checkout", "-q", "-b", "work", "--track", "origin/main")
+		commitFile(t, repo.Path, "work.txt", "work\n", "add work")
+
+		result := repo.PushBranch()
+		if result.Status != src.PushPushed || result.Branch != "main" {
+			t.Fatalf("PushBranch() = %v, want pushed to main", result)
+		}
+		if got, want := git(t, remote, "rev-parse", "main"), git(t, repo.Path, "rev-parse", "HEAD"); got != want {
+			t.Errorf("remote main = %s, want %s", got, want)
+		}
+		if branches := git(t, remote, "branch", "--format=%(refname:short)"); branches != "main" {
+			t.Errorf("remote branches = %q, want only main", branches)
+		}
+	})
+
+	t.Run("tracking a local branch", func(t *testing.T) {
+		repo, remote := newPushRepo(t)
+		git(t, repo.Path, "push", "-q", "origin", "main")
+		main := git(t, repo.Path, "rev-parse", "main")
+		git(t, repo.Path, "checkout", "-q", "-b", "feat", "--track", "main")
+		commitFile(t, repo.Path, "feat.txt", "feat\n", "add feat")
+
+		result := repo.PushBranch()
+		if result.Status != src.PushPushed || !result.SetUpstream || result.Remote != "origin" || result.Branch != "feat" {
+			t.Fatalf("PushBranch() = %v, want origin/feat pushed as upstream", result)
+		}
+		if got := git(t, repo.Path, "rev-parse", "main"); got != main {
+			t.Errorf("local main moved to %s", got)
+		}
+		if got := git(t, remote, "rev-parse", "main"); got != main {
+			t.Errorf("remote main moved to %s", got)
+		}
+		if got := git(t, repo.Path, "rev-parse", "--abbrev-ref", "feat@{upstream}"); got != "origin/feat" {
+			t.Errorf("upstream = %s, want origin/feat", got)
+		}
+	})
+
+	t.Run("rejected then rebased", func(t *testing.T) {
+		repo, remote := newPushRepo(t)
+		repo.PushBranch()
+		pushFromClone(t, remote, "theirs.txt")
+		commitFile(t, repo.Path, "ours.txt", "ours\n", "add ours")
+
+		if result := repo.PushBranch(); result.Status != src.PushRebased {
+			t.Fatalf("PushBranch() = %v, want rebased", result)
+		}
+		if got := git(t, repo.Path, "log", "--format=%s", "-3"); got != "add ours\nadd theirs.txt\ndocs: add readme" {
+			t.Errorf("history after the rebase:\n%s", got)
+		}
+		if got, want := git(t, remote, "rev-parse", "main"), git(t, repo.Path, "rev-parse", "HEAD"); got != want {
+			t.Errorf("remote main = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("rejected with on_rejected stop", func(t *testing.T) {
+		repo, remote := newPushRepo(t)
+		repo.PushBranch()
+		pushFromClone(t, remote, "theirs.txt")
+		head := commitFile(t, repo.Path, "ours.txt", "ours\n", "add ours")
+		writeFile(t, os.Getenv("XDG_CONFIG_HOME"), "zvezda/config.yml", "on_rejected: stop\n")
+
+		if result := repo.PushBranch(); result.Status != src.PushRejected {
+			t.Fatalf("PushBranch() = %v, want rejected", result)
+		}
+		if got := git(t, repo.Path, "rev-parse", "HEAD"); got != head {
+			t.Errorf("HEAD moved to %s, want %s", got, head)
+		}
+		if got := git(t, remote, "log", "--format=%s", "-1", "main"); got != "add theirs.txt" {
+			t.Errorf("remote main = %q, want the other clone's commit", got)
+		}
+	})
+
+	t.Run("protected", func(t *testing.T) {
+		repo, remote := newPushRepo(t)
+		writeFile(t, repo.Path, ".zvezda.yml", "protected_branches: [main, release/*]\n")
+
+		if result := repo.PushBranch(); result.Status != src.PushProtected {
+			t.Fatalf("PushBranch() = %v, want protected", result)
+		}
+		if branches := git(t, remote, "branch"); branches != "" {
+			t.Errorf("remote branches = %q, want none", branches)
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package src
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is a semantic version as found in a tag
+type Version struct {
+	Prefix string // "v" or ""
+	Major  int
+	Minor  int
+	Patch  int
+	Pre    string // pre-release such as "rc.1"
+}
+
+var versionRegex = regexp.MustCompile(`^(v?)(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// ParseVersion parses a tag such as v1.2.3 or 1.2.3-rc.1
+func ParseVersion(tag string) (Version, bool) {
+	m := versionRegex.FindStringSubmatch(strings.TrimSpace(tag))
+	if m == nil {
+		return Version{}, false
+	}
+	major, _ := strconv.Atoi(m[2])
+	minor, _ := strconv.Atoi(m[3])
+	patch, _ := strconv.Atoi(m[4])
+	return Version{Prefix: m[1], Major: major, Minor: minor, Patch: patch, Pre: m[5]}, true
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%s%d.%d.%d", v.Prefix, v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Less orders versions by precedence. Pre-releases come before the
+// release, and are compared identifier by identifier.
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	if v.Patch != o.Patch {
+		return v.Patch < o.Patch
+	}
+	if v.Pre == "" || o.Pre == "" {
+		return v.Pre != "" && o.Pre == ""
+	}
+
+	a, b := strings.Split(v.Pre, "."), strings.Split(o.Pre, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		x, errX := strconv.Atoi(a[i])
+		y, errY := strconv.Atoi(b[i])
+		switch {
+		case errX == nil && errY == nil:
+			return x < y
+		case errX == nil:
+			return true
+		case errY == nil:
+			return false
+		}
+		return a[i] < b[i]
+	}
+	return len(a) < len(b)
+}
+
+// Bump returns the release level required by the commits: "major" for
+// breaking changes, "minor" for features, "patch" for fixes and
+// performance improvements, or "" when nothing warrants a release
+func Bump(entries []ChangelogEntry) string {
+	bump := ""
+	for _, e := range entries {
+		switch {
+		case e.Breaking:
+			return "major"
+		case e.Type == "feat":
+			bump = "minor"
+		case (e.Type == "fix" || e.Type == "perf") && bump == "":
+			bump = "patch"
+		}
+	}
+	return bump
+}
+
+// NextVersion applies a bump to the last stable version. With a
+// pre-release identifier such as "rc", the result is a pre-release of that
+// version, numbered after current when current is already one.
+func NextVersion(stable, current Version, bump, pre string) Version {
+	next := Version{Prefix: stable.Prefix, Major: stable.Major, Minor: stable.Minor, Patch: stable.Patch}
+	switch bump {
+	case "major":
+		next.Major, next.Minor, next.Patch = next.Major+1, 0, 0
+	case "minor":
+		next.Minor, next.Patch = next.Minor+1, 0
+	case "patch":
+		next.Patch++
+	}
+	if pre == "" {
+		return next
+	}
+
+	n := 1
+	sameCore := current.Major == next.Major && current.Minor == next.Minor && current.Patch == next.Patch
+	if id, num, ok := strings.Cut(current.Pre, "."); sameCore && ok && id == pre {
+		if i, err := strconv.Atoi(num); err == nil {
+			n = i + 1
+		}
+	}
+	next.Pre = fmt.Sprintf("%s.%d", pre, n)
+	return next
+}
+
+// ReleasePlan is what the release command is about to tag
+type ReleasePlan struct {
+	Current string // latest version tag, "" when there is none
+	Next    Version
+	Bump    string
+	Notes   ChangelogRelease
+}
+
+// versionTags returns the latest version tag and the latest stable one
+// reachable from HEAD
+func (r *Repo) versionTags() (latest, stable string) {
+	var latestV, stableV Version
+	for _, tag := range r.Tags() {
+		v, ok := ParseVersion(tag)
+		if !ok {
+			continue
+		}
+		if latest == "" || latestV.Less(v) {
+			latest, latestV = tag, v
+		}
+		if v.Pre == "" && (stable == "" || stableV.Less(v)) {
+			stable, stableV = tag, v
+		}
+	}
+	return latest, stable
+}
+
+// PlanRelease computes the next version from the commits since the last
+// stable tag. It fails when those commits hold nothing worth releasing,
+// or when nothing was committed since the latest tag and that tag is not
+// a pre-release being promoted.
+func (r *Repo) PlanRelease(pre string) (ReleasePlan, error) {
+	latest, stable := r.versionTags()
+	latestV, _ := ParseVersion(latest)
+
+	// Promoting a pre-release needs no new commits
+	if latest != "" && (pre != "" || latestV.Pre == "") {
+		out, err := r.Git("rev-list", "--count", latest+"..HEAD")
+		if err == nil && strings.TrimSpace(out) == "0" {
+			return ReleasePlan{}, fmt.Errorf("nothing was committed since %s", latest)
+		}
+	}
+
+	entries, err := r.ConventionalHistory(stable, "HEAD")
+	if err != nil {
+		return ReleasePlan{}, err
+	}
+	bump := Bump(entries)
+	if bump == "" {
+		return ReleasePlan{}, fmt.Errorf("no feat, fix or breaking commits since %s", withDefault(stable, "the first commit"))
+	}
+
+	stableV := Version{Prefix: "v"}
+	if v, ok := ParseVersion(stable); ok {
+		stableV = v
+	}
+	if latest == "" {
+		latestV.Prefix = stableV.Prefix
+	}
+
+	next := NextVersion(stableV, latestV, bump, pre)
+	return ReleasePlan{
+		Current: latest,
+		Next:    next,
+		Bump:    bump,
+		Notes:   BuildRelease(next.String(), time.Now().Format("2006-01-02"), entries, false),
+	}, nil
+}
+
+// CreateRelease creates the annotated tag of the plan, with the release
+// notes as its message, and pushes it to remote when push is set
+func (r *Repo) CreateRelease(plan ReleasePlan, push bool, remote string) error {
+	tag := plan.Next.String()
+	if _, err := r.GitInput(plan.Notes.Markdown(), "tag", "-a", "--cleanup=verbatim", tag, "-F", "-"); err != nil {
+		return err
+	}
+	if !push {
+		return nil
+	}
+	return r.Push(withDefault(remote, "origin"), "refs/tags/"+tag)
+}
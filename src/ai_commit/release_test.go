@@ -0,0 +1,45 @@
+package src_test
+
+import (
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestNextVersion(t *testing.T) {
+	tests := []struct {
+		stable  string
+		current string
+		bump    string
+		pre     string
+		want    string
+	}{
+		{stable: "v1.2.3", current: "v1.2.3", bump: "major", want: "v2.0.0"},
+		{stable: "v1.2.3", current: "v1.2.3", bump: "minor", want: "v1.3.0"},
+		{stable: "1.2.3", current: "1.2.3", bump: "patch", want: "1.2.4"},
+		{stable: "v1.2.3", current: "v1.2.3", bump: "minor", pre: "rc", want: "v1.3.0-rc.1"},
+		{stable: "v1.2.3", current: "v1.3.0-rc.1", bump: "minor", pre: "rc", want: "v1.3.0-rc.2"},
+		{stable: "v1.2.3", current: "v1.2.4-rc.2", bump: "minor", pre: "rc", want: "v1.3.0-rc.1"},
+		{stable: "v1.2.3", current: "v1.3.0-rc.2", bump: "minor", want: "v1.3.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.current+" "+tt.bump+" "+tt.pre, func(t *testing.T) {
+			stable, _ := src.ParseVersion(tt.stable)
+			current, _ := src.ParseVersion(tt.current)
+			if got := src.NextVersion(stable, current, tt.bump, tt.pre).String(); got != tt.want {
+				t.Errorf("NextVersion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionLess(t *testing.T) {
+	ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.1.0"}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, _ := src.ParseVersion(ordered[i])
+		b, _ := src.ParseVersion(ordered[i+1])
+		if !a.Less(b) || b.Less(a) {
+			t.Errorf("expected %s < %s", ordered[i], ordered[i+1])
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Repo is a git repository on disk. Every git command runs with its
+// working directory set to the repository root, so several repositories can
+// be used at once without changing the process directory.
+type Repo struct {
+	// Path is the top level directory of the working tree
+	Path string
+
+	ctx context.Context
+}
+
+// GitError is a failed git command with what it wrote to stderr
+type GitError struct {
+	Args   []string
+	Err    error
+	Stderr string
+}
+
+func (e *GitError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("git %s: %v", e.subcommand(), e.Err)
+	}
+	return fmt.Sprintf("git %s: %v: %s", e.subcommand(), e.Err, e.Stderr)
+}
+
+// subcommand skips the -c options placed before the git subcommand
+func (e *GitError) subcommand() string {
+	args := e.Args
+	for len(args) > 2 && args[0] == "-c" {
+		args = args[2:]
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+// OpenRepo returns the repository containing path
+func OpenRepo(path string) (*Repo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Repo{Path: abs}
+	root, err := r.Git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a git repository: %w", path, err)
+	}
+	r.Path = strings.TrimSpace(root)
+	return r, nil
+}
+
+// WithContext returns a copy of the repository whose git commands are
+// killed when ctx is done
+func (r *Repo) WithContext(ctx context.Context) *Repo {
+	copy := *r
+	copy.ctx = ctx
+	return &copy
+}
+
+func (r *Repo) command(args ...string) *exec.Cmd {
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Path
+	return cmd
+}
+
+// Git runs a git command in the repository and returns its output
+func (r *Repo) Git(args ...string) (string, error) {
+	return r.GitInput("", args...)
+}
+
+// GitInput runs a git command with input on stdin
+func (r *Repo) GitInput(input string, args ...string) (string, error) {
+	return r.gitEnv(nil, input, args...)
+}
+
+// gitEnv runs a git command with extra environment variables, such as the
+// GIT_AUTHOR_* ones
+func (r *Repo) gitEnv(env []string, input string, args ...string) (string, error) {
+	cmd := r.command(args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), &GitError{Args: args, Err: err, Stderr: strings.TrimSpace(stderr.String())}
+	}
+	return stdout.String(), nil
+}
+
+// gitLines runs a git command and returns its non-empty output lines
+func (r *Repo) gitLines(args ...string) ([]string, error) {
+	out, err := r.Git(args...)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// Abs returns the absolute path of a file given relative to the root
+func (r *Repo) Abs(file string) string {
+	return filepath.Join(r.Path, filepath.FromSlash(file))
+}
+
+// Status returns the status in porcelain format
+func (r *Repo) Status() (string, error) {
+	return r.Git("status", "--porcelain")
+}
+
+// Diff returns the unstaged changes
+func (r *Repo) Diff() (string, error) {
+	return r.Git("diff")
+}
+
+// StagedDiff returns the staged changes
+func (r *Repo) StagedDiff() (string, error) {
+	return r.Git("diff", "--staged")
+}
+
+// Branch returns the current branch name
+func (r *Repo) Branch() (string, error) {
+	out, err := r.Git("rev-parse", "--abbrev-ref", "HEAD")
+	return strings.TrimSpace(out), err
+}
+
+// Log runs git log with the given arguments
+func (r *Repo) Log(args ...string) (string, error) {
+	return r.Git(append([]string{"log"}, args...)...)
+}
+
+// LastCommit returns the last commit message
+func (r *Repo) LastCommit() (string, error) {
+	out, err := r.Log("-1", "--pretty=%B")
+	return strings.TrimSpace(out), err
+}
+
+// ChangedFiles returns the files with unstaged changes
+func (r *Repo) ChangedFiles() ([]string, error) {
+	return r.gitLines("diff", "--name-only")
+}
+
+// StagedFiles returns the files with staged changes
+func (r *Repo) StagedFiles() ([]string, error) {
+	return r.gitLines("diff", "--staged", "--name-only")
+}
+
+// UntrackedFiles returns the untracked files that are not ignored
+func (r *Repo) UntrackedFiles() ([]string, error) {
+	return r.gitLines("ls-files", "--others", "--exclude-standard")
+}
+
+// Show returns an object such as HEAD:path or :path (the index)
+func (r *Repo) Show(object string) ([]byte, error) {
+	out, err := r.Git("show", object)
+	return []byte(out), err
+}
+
+// Add stages the given paths, including deletions, or everything under
+// the root when none are given
+func (r *Repo) Add(paths ...string) error {
+	if len(paths) == 0 {
+		_, err := r.Git("add", ".")
+		return err
+	}
+	_, err := r.Git(append([]string{"add", "-A", "--"}, paths...)...)
+	return err
+}
+
+// Commit commits the index with the message. args are passed to git
+// commit, such as --no-verify. The author identity of the directory and the
+// signing settings are applied, see commitSettings, and the CoAuthors of
+// opts are credited.
+func (r *Repo) Commit(message string, opts Options, args ...string) error {
+	return r.commit(nil, message, opts, args...)
+}
+
+// commit is Commit with extra environment variables, such as a temporary
+// GIT_INDEX_FILE
+func (r *Repo) commit(env []string, message string, opts Options, args ...string) error {
+	global, signArgs := r.commitSettings(opts.Sign)
+	command := append(global, "commit", "-F", "-")
+	command = append(command, signArgs...)
+	_, err := r.gitEnv(env, AddCoAuthors(message, opts.CoAuthors), append(command, args...)...)
+	return err
+}
+
+// Push runs git push with the given arguments
+func (r *Repo) Push(args ...string) error {
+	_, err := r.Git(append([]string{"push"}, args...)...)
+	return err
+}
+
+// Pull runs git pull with the given arguments
+func (r *Repo) Pull(args ...string) error {
+	_, err := r.Git(append([]string{"pull"}, args...)...)
+	return err
+}
+
+// HasChanges reports whether the working tree or the index has changes,
+// untracked files included
+func (r *Repo) HasChanges() (bool, error) {
+	status, err := r.Status()
+	return strings.TrimSpace(status) != "", err
+}
@@ -0,0 +1,78 @@
+package src_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+// isolateGit keeps the user's git and zvezda configuration out of the test
+// and sets an identity for the commits
+func isolateGit(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(home, ".gitconfig"))
+	for _, prefix := range []string{"GIT_AUTHOR_", "GIT_COMMITTER_"} {
+		t.Setenv(prefix+"NAME", "Test")
+		t.Setenv(prefix+"EMAIL", "test@example.com")
+	}
+	for _, name := range []string{"ZVEZDA_PROVIDER", "ZVEZDA_MODEL", "ZVEZDA_ENDPOINT", "ZVEZDA_TEMPLATE"} {
+		t.Setenv(name, "") // empty is the same as unset
+	}
+}
+
+// git runs a git command in dir and fails the test if it fails
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	return gitEnv(t, dir, nil, args...)
+}
+
+func gitEnv(t *testing.T, dir string, env []string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newTestRepo creates a repository with one commit on main
+func newTestRepo(t *testing.T) *src.Repo {
+	t.Helper()
+	isolateGit(t)
+	dir := t.TempDir()
+	git(t, dir, "init", "-q", "-b", "main")
+	commitFile(t, dir, "README.md", "# test\n", "docs: add readme")
+
+	repo, err := src.OpenRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+// commitFile writes a file and commits it with the message
+func commitFile(t *testing.T, dir, name, content, message string, env ...string) string {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, dir, "add", name)
+	gitEnv(t, dir, env, "commit", "-q", "-m", message)
+	return git(t, dir, "rev-parse", "HEAD")
+}
@@ -0,0 +1,578 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Styles
+var (
+	reviewTitleStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#89b4fa")).
+				Background(lipgloss.Color("#313244")).
+				Padding(0, 2).
+				Bold(true)
+
+	reviewBoxStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#89b4fa")).
+			Padding(0, 1)
+
+	reviewHelpStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#6c7086")).
+			Italic(true)
+
+	reviewWarningStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#f9e2af")).
+				Bold(true)
+
+	reviewAddedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#a6e3a1"))
+	reviewRemovedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#f38ba8"))
+	reviewHunkStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#cba6f7"))
+)
+
+// ReviewResult is what the user decided on the review screen
+type ReviewResult struct {
+	Message   string
+	Accepted  bool
+	Push      bool
+	CoAuthors []Teammate // set as Options.CoAuthors before committing
+}
+
+// Messages
+type tokenMsg struct {
+	gen   int
+	token string
+	reset bool // discard the text streamed so far
+}
+
+type generationDoneMsg struct {
+	gen    int
+	result GeneratedMessage
+	err    error
+}
+
+type candidatesDoneMsg struct {
+	gen        int
+	candidates []Candidate
+	err        error
+}
+
+type reviewModel struct {
+	ctx     context.Context
+	repo    *Repo
+	changes Changes
+	opts    Options
+	prompt  string
+	files   []string
+
+	// candidates > 1 generates several messages to choose from
+	candidates int
+	choices    []Candidate
+	cursor     int
+
+	// teammates from the config, the ones picked are credited as co-authors
+	teammates []Teammate
+	coAuthors []bool
+	coCursor  int
+
+	state      string // "generating", "choosing", "review", "editing", "instructing", "coauthors", "confirm", "done"
+	gen        int
+	cancelGen  context.CancelFunc
+	events     chan tea.Msg
+	streamed   string
+	message    string
+	note       string
+	editor     textarea.Model
+	input      textinput.Model
+	diff       viewport.Model
+	result     ReviewResult
+	windowW    int
+	windowH    int
+	diffLoaded bool
+	diffText   string
+	initCmd    tea.Cmd
+}
+
+func newReviewModel(ctx context.Context, repo *Repo, changes Changes, opts Options, prompt string, candidates int) reviewModel {
+	editor := textarea.New()
+	editor.ShowLineNumbers = false
+	editor.SetHeight(6)
+
+	input := textinput.New()
+	input.Placeholder = "e.g. mention the new flag, keep it shorter"
+	input.Prompt = "Instructions: "
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, file := range changes.StagedFiles {
+		seen[file] = true
+		files = append(files, file)
+	}
+	for _, file := range changes.UnstagedFiles {
+		if !seen[file] {
+			files = append(files, file+" (will be staged)")
+		}
+	}
+
+	// Co-authors given on the command line start out selected
+	config, _ := LoadConfig()
+	teammates := config.Teammates
+	var coAuthors []bool
+	for _, t := range teammates {
+		coAuthors = append(coAuthors, containsTeammate(opts.CoAuthors, t))
+	}
+	for _, t := range opts.CoAuthors {
+		if !containsTeammate(teammates, t) {
+			teammates = append(teammates, t)
+			coAuthors = append(coAuthors, true)
+		}
+	}
+
+	return reviewModel{
+		ctx:        ctx,
+		repo:       repo,
+		changes:    changes,
+		opts:       opts,
+		prompt:     prompt,
+		files:      files,
+		candidates: candidates,
+		teammates:  teammates,
+		coAuthors:  coAuthors,
+		state:      "generating",
+		editor:     editor,
+		input:      input,
+		diff:       viewport.New(80, 10),
+		diffText:   colorDiff(changes.Diff()),
+	}
+}
+
+func (m reviewModel) Init() tea.Cmd {
+	return m.initCmd
+}
+
+// startGeneration runs the generator in the background, streaming its
+// tokens into the model through the events channel
+func (m *reviewModel) startGeneration(prompt string) tea.Cmd {
+	if m.cancelGen != nil {
+		m.cancelGen()
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.cancelGen = cancel
+	m.gen++
+	m.state = "generating"
+	m.streamed = ""
+	m.note = ""
+
+	gen := m.gen
+	events := make(chan tea.Msg, 64)
+	m.events = events
+
+	// Sends give up once the generation is cancelled so that a replaced
+	// generation does not block on a channel nobody reads anymore
+	send := func(msg tea.Msg) {
+		select {
+		case events <- msg:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(events)
+		if m.candidates > 1 {
+			candidates, err := m.repo.GenerateCandidates(ctx, m.changes, m.opts, prompt, m.candidates)
+			send(candidatesDoneMsg{gen: gen, candidates: candidates, err: err})
+			return
+		}
+		result, err := m.repo.GenerateCommitMessage(ctx, m.changes, m.opts, prompt, Stream{
+			OnToken: func(token string) { send(tokenMsg{gen: gen, token: token}) },
+			OnReset: func() { send(tokenMsg{gen: gen, reset: true}) },
+		})
+		send(generationDoneMsg{gen: gen, result: result, err: err})
+	}()
+
+	return listen(events)
+}
+
+func listen(events chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-events
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+func (m reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.windowW, m.windowH = msg.Width, msg.Height
+		m.diff.Width = msg.Width - 4
+		m.diff.Height = max(5, msg.Height-len(m.files)-18)
+		m.editor.SetWidth(msg.Width - 6)
+		if !m.diffLoaded {
+			m.diff.SetContent(m.diffText)
+			m.diffLoaded = true
+		}
+		return m, nil
+
+	case tokenMsg:
+		if msg.gen != m.gen {
+			return m, nil
+		}
+		if msg.reset {
+			m.streamed = ""
+		}
+		m.streamed += msg.token
+		return m, listen(m.events)
+
+	case generationDoneMsg:
+		if msg.gen != m.gen {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.note = fmt.Sprintf("Generation stopped: %v", msg.err)
+		} else if msg.result.Fallback {
+			m.note = fmt.Sprintf("Using template message: %s", msg.result.Reason)
+		} else if msg.result.Cached {
+			m.note = "Reusing the message generated earlier for these changes, press r to regenerate"
+		}
+		m.message = msg.result.Message
+		m.state = "review"
+		return m, nil
+
+	case candidatesDoneMsg:
+		if msg.gen != m.gen {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.note = fmt.Sprintf("Generation stopped: %v", msg.err)
+			m.state = "review"
+			return m, nil
+		}
+		m.choices = msg.candidates
+		m.cursor = 0
+		m.state = "choosing"
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m.abort()
+		}
+		return m.handleKey(msg)
+	}
+
+	return m, nil
+}
+
+func (m reviewModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch m.state {
+	case "generating":
+		switch msg.String() {
+		case "q", "esc":
+			return m.abort()
+		}
+		m.diff, cmd = m.diff.Update(msg)
+		return m, cmd
+
+	case "choosing":
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.choices)-1 {
+				m.cursor++
+			}
+		case "enter":
+			m.message = m.choices[m.cursor].Message
+			m.state = "review"
+		case "r":
+			m.state = "instructing"
+			m.input.SetValue("")
+			return m, m.input.Focus()
+		case "q", "esc":
+			return m.abort()
+		}
+		return m, nil
+
+	case "review":
+		switch msg.String() {
+		case "a", "enter":
+			if strings.TrimSpace(m.message) == "" {
+				m.note = "The message is empty, edit or regenerate it first"
+				return m, nil
+			}
+			m.state = "confirm"
+			return m, nil
+		case "e":
+			m.state = "editing"
+			m.editor.SetValue(m.message)
+			return m, m.editor.Focus()
+		case "r":
+			m.state = "instructing"
+			m.input.SetValue("")
+			return m, m.input.Focus()
+		case "l":
+			if len(m.choices) > 1 {
+				m.state = "choosing"
+				return m, nil
+			}
+		case "o":
+			if len(m.teammates) == 0 {
+				m.note = "No teammates in the config, see teammates in config.yml"
+				return m, nil
+			}
+			m.state = "coauthors"
+			return m, nil
+		case "q", "esc":
+			return m.abort()
+		}
+		m.diff, cmd = m.diff.Update(msg)
+		return m, cmd
+
+	case "coauthors":
+		switch msg.String() {
+		case "up", "k":
+			if m.coCursor > 0 {
+				m.coCursor--
+			}
+		case "down", "j":
+			if m.coCursor < len(m.teammates)-1 {
+				m.coCursor++
+			}
+		case " ", "x":
+			m.coAuthors[m.coCursor] = !m.coAuthors[m.coCursor]
+		case "enter", "esc":
+			m.state = "review"
+		}
+		return m, nil
+
+	case "editing":
+		switch msg.String() {
+		case "ctrl+s":
+			m.message = strings.TrimSpace(m.editor.Value())
+			m.editor.Blur()
+			m.state = "review"
+			rules, _ := m.repo.Rules(m.opts)
+			if violations := Validate(m.message, rules); len(violations) > 0 {
+				m.note = "Warning: " + strings.Join(violations, "; ")
+			} else {
+				m.note = ""
+			}
+			return m, nil
+		case "esc":
+			m.editor.Blur()
+			m.state = "review"
+			return m, nil
+		}
+		m.editor, cmd = m.editor.Update(msg)
+		return m, cmd
+
+	case "instructing":
+		switch msg.String() {
+		case "enter":
+			m.input.Blur()
+			prompt := m.prompt
+			if extra := strings.TrimSpace(m.input.Value()); extra != "" {
+				prompt += "\n\nAdditional instructions from the user:\n" + extra
+			}
+			// A regenerated message must come from the model
+			m.opts.NoCache = true
+			return m, m.startGeneration(prompt)
+		case "esc":
+			m.input.Blur()
+			m.state = "review"
+			return m, nil
+		}
+		m.input, cmd = m.input.Update(msg)
+		return m, cmd
+
+	case "confirm":
+		switch msg.String() {
+		case "y", "p":
+			m.result = ReviewResult{Message: m.message, Accepted: true, Push: true, CoAuthors: m.selectedCoAuthors()}
+			m.state = "done"
+			return m, tea.Quit
+		case "c":
+			m.result = ReviewResult{Message: m.message, Accepted: true, CoAuthors: m.selectedCoAuthors()}
+			m.state = "done"
+			return m, tea.Quit
+		case "n", "esc":
+			m.state = "review"
+			return m, nil
+		}
+	}
+
+	return m, nil
+}
+
+func (m reviewModel) selectedCoAuthors() []Teammate {
+	var selected []Teammate
+	for i, t := range m.teammates {
+		if m.coAuthors[i] {
+			selected = append(selected, t)
+		}
+	}
+	return selected
+}
+
+func containsTeammate(teammates []Teammate, t Teammate) bool {
+	for _, other := range teammates {
+		if strings.EqualFold(other.Email, t.Email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m reviewModel) abort() (tea.Model, tea.Cmd) {
+	if m.cancelGen != nil {
+		m.cancelGen()
+	}
+	m.result = ReviewResult{}
+	m.state = "done"
+	return m, tea.Quit
+}
+
+func (m reviewModel) View() string {
+	if m.state == "done" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(reviewTitleStyle.Render(" Review commit") + "\n\n")
+
+	// Commit message
+	message := m.message
+	if m.state == "generating" {
+		message = m.streamed + "▌"
+	}
+	if m.state == "generating" && m.candidates > 1 {
+		message = fmt.Sprintf("Generating %d candidates...", m.candidates)
+	}
+	if m.state == "choosing" {
+		var list strings.Builder
+		for i, c := range m.choices {
+			cursor := "  "
+			if i == m.cursor {
+				cursor = "> "
+			}
+			header := strings.SplitN(c.Message, "\n", 2)[0]
+			list.WriteString(fmt.Sprintf("%s%s %s\n", cursor, header, reviewHelpStyle.Render("("+c.Style+")")))
+		}
+		b.WriteString(reviewBoxStyle.Width(max(20, m.windowW-4)).Render(strings.TrimRight(list.String(), "\n")) + "\n")
+		if len(m.choices) > 0 {
+			b.WriteString(reviewBoxStyle.Width(max(20, m.windowW-4)).Render(m.choices[m.cursor].Message) + "\n")
+		}
+	} else if m.state == "editing" {
+		b.WriteString(reviewBoxStyle.Render(m.editor.View()) + "\n")
+	} else if m.state == "coauthors" {
+		var list strings.Builder
+		for i, t := range m.teammates {
+			cursor := "  "
+			if i == m.coCursor {
+				cursor = "> "
+			}
+			check := "[ ]"
+			if m.coAuthors[i] {
+				check = "[x]"
+			}
+			list.WriteString(fmt.Sprintf("%s%s %s %s\n", cursor, check, t.Name, reviewHelpStyle.Render("<"+t.Email+">")))
+		}
+		b.WriteString(reviewBoxStyle.Width(max(20, m.windowW-4)).Render(strings.TrimRight(list.String(), "\n")) + "\n")
+	} else {
+		b.WriteString(reviewBoxStyle.Width(max(20, m.windowW-4)).Render(message) + "\n")
+	}
+	if selected := m.selectedCoAuthors(); len(selected) > 0 && m.state != "coauthors" {
+		var names []string
+		for _, t := range selected {
+			names = append(names, t.Name)
+		}
+		b.WriteString(reviewHelpStyle.Render("Co-authored with "+strings.Join(names, ", ")) + "\n")
+	}
+	if m.note != "" {
+		b.WriteString(reviewWarningStyle.Render(m.note) + "\n")
+	}
+
+	// Files
+	b.WriteString("\n" + reviewTitleStyle.Render(fmt.Sprintf(" Files (%d)", len(m.files))) + "\n")
+	for _, file := range m.files {
+		b.WriteString("  " + file + "\n")
+	}
+
+	// Diff
+	b.WriteString("\n" + reviewTitleStyle.Render(" Diff") + "\n")
+	b.WriteString(m.diff.View() + "\n\n")
+
+	switch m.state {
+	case "generating":
+		b.WriteString(reviewHelpStyle.Render("Generating... ↑/↓ scroll diff • q abort"))
+	case "choosing":
+		b.WriteString(reviewHelpStyle.Render("↑/↓ select • enter choose • r regenerate with instructions • q abort"))
+	case "review":
+		help := "a accept • e edit • r regenerate with instructions • o co-authors • ↑/↓ scroll diff • q abort"
+		if len(m.choices) > 1 {
+			help = "a accept • e edit • l back to list • r regenerate with instructions • o co-authors • ↑/↓ scroll diff • q abort"
+		}
+		b.WriteString(reviewHelpStyle.Render(help))
+	case "coauthors":
+		b.WriteString(reviewHelpStyle.Render("↑/↓ select • space toggle • enter done"))
+	case "editing":
+		b.WriteString(reviewHelpStyle.Render("ctrl+s save • esc cancel"))
+	case "instructing":
+		b.WriteString(m.input.View() + "\n")
+		b.WriteString(reviewHelpStyle.Render("enter regenerate • esc cancel"))
+	case "confirm":
+		b.WriteString(reviewWarningStyle.Render("Commit and push? y push • c commit only • n back"))
+	}
+
+	return b.String()
+}
+
+func colorDiff(diff string) string {
+	if strings.TrimSpace(diff) == "" {
+		return reviewHelpStyle.Render("No diff")
+	}
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			lines[i] = reviewAddedStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = reviewRemovedStyle.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = reviewHunkStyle.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Review shows the interactive review screen: the message streams in, and
+// the user can accept, edit, regenerate with extra instructions or abort.
+// With candidates > 1 several messages are generated and picked from a
+// list. Pushing requires an explicit confirmation.
+func (r *Repo) Review(ctx context.Context, changes Changes, opts Options, prompt string, candidates int) (ReviewResult, error) {
+	m := newReviewModel(ctx, r, changes, opts, prompt, candidates)
+	m.initCmd = m.startGeneration(prompt)
+
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx))
+
+	final, err := p.Run()
+	if err != nil {
+		return ReviewResult{}, err
+	}
+	return final.(reviewModel).result, nil
+}
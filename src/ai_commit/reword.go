@@ -0,0 +1,385 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// RewordCommit is an existing commit the reword mode proposes a new
+// message for
+type RewordCommit struct {
+	Hash       string
+	OldMessage string
+	Message    string
+	Approved   bool
+}
+
+// Short returns the abbreviated hash
+func (c RewordCommit) Short() string {
+	return c.Hash[:min(len(c.Hash), 7)]
+}
+
+// RewordCommits returns the commits of spec, oldest first: a single commit
+// such as HEAD, or a range such as main..HEAD. The commits must be on the
+// current branch with no merge between them and HEAD, and must not be
+// pushed to a remote yet unless force is set.
+func (r *Repo) RewordCommits(spec string, force bool) ([]RewordCommit, error) {
+	if spec == "" {
+		spec = "HEAD"
+	}
+
+	var hashes []string
+	if strings.Contains(spec, "..") {
+		lines, err := r.gitLines("rev-list", "--reverse", spec)
+		if err != nil {
+			return nil, err
+		}
+		hashes = lines
+	} else {
+		out, err := r.Git("rev-parse", "--verify", "--quiet", spec+"^{commit}")
+		if err != nil {
+			return nil, fmt.Errorf("unknown commit %s", spec)
+		}
+		hashes = []string{strings.TrimSpace(out)}
+	}
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("no commits in %s", spec)
+	}
+
+	unpushed := make(map[string]bool)
+	lines, _ := r.gitLines("rev-list", "HEAD", "--not", "--remotes")
+	for _, hash := range lines {
+		unpushed[hash] = true
+	}
+
+	var commits []RewordCommit
+	for _, hash := range hashes {
+		c := RewordCommit{Hash: hash}
+		if _, err := r.Git("merge-base", "--is-ancestor", hash, "HEAD"); err != nil {
+			return nil, fmt.Errorf("%s is not on the current branch", c.Short())
+		}
+		if !force && !unpushed[hash] {
+			return nil, fmt.Errorf("%s is already pushed, rewording it rewrites published history (use --force)", c.Short())
+		}
+		out, err := r.Git("log", "-1", "--format=%B", hash)
+		if err != nil {
+			return nil, err
+		}
+		c.OldMessage = strings.TrimSpace(out)
+		commits = append(commits, c)
+	}
+
+	// Rewriting replays everything from the oldest commit to HEAD
+	if _, err := r.rewriteChain(hashes[0]); err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// rewriteChain returns the commits from oldest to HEAD, each with its
+// parents, refusing merges which commit-tree cannot replay faithfully
+func (r *Repo) rewriteChain(oldest string) ([][]string, error) {
+	out, err := r.Git("rev-list", "--parents", "-n", "1", oldest)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"rev-list", "--reverse", "--parents", "HEAD"}
+	if parents := strings.Fields(out)[1:]; len(parents) > 0 {
+		args = append(args, "--not", parents[0])
+	}
+	lines, err := r.gitLines(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var chain [][]string
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("%s is a merge commit, merges cannot be reworded", fields[0][:7])
+		}
+		chain = append(chain, fields)
+	}
+	return chain, nil
+}
+
+// GenerateRewordMessages generates a message for every commit from its own
+// diff. Commits whose message would not change are not approved.
+func (r *Repo) GenerateRewordMessages(ctx context.Context, commits []RewordCommit, opts Options, onProgress func(i int)) error {
+	for i := range commits {
+		if onProgress != nil {
+			onProgress(i)
+		}
+		changes, err := r.CommitChanges(commits[i].Hash)
+		if err != nil {
+			return err
+		}
+		prompt, err := r.GenerateCommitPrompt(changes, opts)
+		if err != nil {
+			return err
+		}
+		if prompt == "" {
+			// Empty commits have nothing to describe
+			commits[i].Message = commits[i].OldMessage
+			continue
+		}
+		result, err := r.GenerateCommitMessage(ctx, changes, opts, prompt, Stream{})
+		if err != nil {
+			return err
+		}
+		commits[i].Message = result.Message
+		commits[i].Approved = strings.TrimSpace(result.Message) != commits[i].OldMessage
+	}
+	return nil
+}
+
+// ApplyReword rewrites the messages of the approved commits with git
+// commit-tree, replaying the commits after them on top, and moves the
+// current branch with update-ref. Trees, authors and author dates are
+// kept, the working tree and the index are not touched. It returns how
+// many messages were rewritten. Options.Sign signs the new commits.
+func (r *Repo) ApplyReword(commits []RewordCommit, opts Options) (int, error) {
+	messages := make(map[string]string)
+	oldest := ""
+	for _, c := range commits {
+		if !c.Approved || strings.TrimSpace(c.Message) == "" || strings.TrimSpace(c.Message) == c.OldMessage {
+			continue
+		}
+		messages[c.Hash] = strings.TrimSpace(c.Message) + "\n"
+		if oldest == "" {
+			oldest = c.Hash
+		}
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	out, err := r.Git("rev-parse", "HEAD")
+	if err != nil {
+		return 0, err
+	}
+	head := strings.TrimSpace(out)
+
+	chain, err := r.rewriteChain(oldest)
+	if err != nil {
+		return 0, err
+	}
+
+	global, signArgs := r.commitSettings(opts.Sign)
+	rewritten := make(map[string]string)
+	last := head
+	for _, fields := range chain {
+		hash, parents := fields[0], fields[1:]
+
+		message, ok := messages[hash]
+		if !ok {
+			if len(parents) == 0 || rewritten[parents[0]] == "" {
+				last = hash
+				continue // nothing before it changed
+			}
+			out, err := r.Git("log", "-1", "--format=%B", hash)
+			if err != nil {
+				return 0, err
+			}
+			message = out
+		}
+
+		out, err := r.Git("log", "-1", "--format=%an%x1f%ae%x1f%ad", "--date=raw", hash)
+		if err != nil {
+			return 0, err
+		}
+		author := strings.Split(strings.TrimSpace(out), "\x1f")
+		if len(author) != 3 {
+			return 0, fmt.Errorf("reading the author of %s: %q", hash, out)
+		}
+		env := []string{"GIT_AUTHOR_NAME=" + author[0], "GIT_AUTHOR_EMAIL=" + author[1], "GIT_AUTHOR_DATE=" + author[2]}
+
+		args := append(append([]string{}, global...), "commit-tree", hash+"^{tree}")
+		for _, parent := range parents {
+			if p := rewritten[parent]; p != "" {
+				parent = p
+			}
+			args = append(args, "-p", parent)
+		}
+		args = append(append(args, signArgs...), "-F", "-")
+
+		out, err = r.gitEnv(env, message, args...)
+		if err != nil {
+			return 0, err
+		}
+		rewritten[hash] = strings.TrimSpace(out)
+		last = rewritten[hash]
+	}
+
+	if _, err := r.Git("update-ref", "-m", "ai_commit: reword", "HEAD", last, head); err != nil {
+		return 0, err
+	}
+	return len(messages), nil
+}
+
+type rewordModel struct {
+	repo    *Repo
+	opts    Options
+	commits []RewordCommit
+	cursor  int
+	state   string // "list", "editing", "confirm", "done"
+	editor  textarea.Model
+	note    string
+	accept  bool
+	windowW int
+}
+
+func (m rewordModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m rewordModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.windowW = msg.Width
+		m.editor.SetWidth(max(20, msg.Width/2-6))
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			m.state = "done"
+			return m, tea.Quit
+		}
+
+		switch m.state {
+		case "list":
+			switch msg.String() {
+			case "up", "k":
+				if m.cursor > 0 {
+					m.cursor--
+				}
+			case "down", "j":
+				if m.cursor < len(m.commits)-1 {
+					m.cursor++
+				}
+			case " ", "x":
+				m.commits[m.cursor].Approved = !m.commits[m.cursor].Approved
+			case "e":
+				m.state = "editing"
+				m.editor.SetValue(m.commits[m.cursor].Message)
+				return m, m.editor.Focus()
+			case "enter":
+				m.state = "confirm"
+			case "q", "esc":
+				m.state = "done"
+				return m, tea.Quit
+			}
+
+		case "editing":
+			switch msg.String() {
+			case "ctrl+s":
+				message := strings.TrimSpace(m.editor.Value())
+				m.commits[m.cursor].Message = message
+				m.commits[m.cursor].Approved = message != m.commits[m.cursor].OldMessage
+				m.editor.Blur()
+				m.state = "list"
+				rules, _ := m.repo.Rules(m.opts)
+				if violations := Validate(message, rules); len(violations) > 0 {
+					m.note = "Warning: " + strings.Join(violations, "; ")
+				} else {
+					m.note = ""
+				}
+				return m, nil
+			case "esc":
+				m.editor.Blur()
+				m.state = "list"
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.editor, cmd = m.editor.Update(msg)
+			return m, cmd
+
+		case "confirm":
+			switch msg.String() {
+			case "y":
+				m.accept = true
+				m.state = "done"
+				return m, tea.Quit
+			case "n", "esc":
+				m.state = "list"
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m rewordModel) View() string {
+	if m.state == "done" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(reviewTitleStyle.Render(" Reword commits") + "\n\n")
+
+	approved := 0
+	for i, c := range m.commits {
+		cursor := "  "
+		if i == m.cursor {
+			cursor = "> "
+		}
+		check := "[ ]"
+		if c.Approved {
+			check = reviewAddedStyle.Render("[x]")
+			approved++
+		}
+		header := strings.SplitN(c.OldMessage, "\n", 2)[0]
+		b.WriteString(fmt.Sprintf("%s%s %s %s\n", cursor, check, reviewHelpStyle.Render(c.Short()), header))
+	}
+
+	if len(m.commits) > 0 {
+		c := m.commits[m.cursor]
+		width := max(20, (m.windowW-6)/2)
+		old := reviewBoxStyle.Width(width).Render(reviewHelpStyle.Render("Current") + "\n" + c.OldMessage)
+		var proposed string
+		if m.state == "editing" {
+			proposed = reviewBoxStyle.Render(m.editor.View())
+		} else {
+			proposed = reviewBoxStyle.Width(width).Render(reviewHelpStyle.Render("Proposed") + "\n" + c.Message)
+		}
+		b.WriteString("\n" + lipgloss.JoinHorizontal(lipgloss.Top, old, " ", proposed) + "\n")
+	}
+	if m.note != "" {
+		b.WriteString(reviewWarningStyle.Render(m.note) + "\n")
+	}
+	b.WriteString("\n")
+
+	switch m.state {
+	case "list":
+		b.WriteString(reviewHelpStyle.Render(fmt.Sprintf("%d approved • space toggle • e edit message • enter rewrite • q abort", approved)))
+	case "editing":
+		b.WriteString(reviewHelpStyle.Render("ctrl+s save • esc cancel"))
+	case "confirm":
+		b.WriteString(reviewWarningStyle.Render(fmt.Sprintf("Rewrite the messages of %d commits? y yes • n back", approved)))
+	}
+	return b.String()
+}
+
+// ReviewReword shows the current and proposed messages side by side and
+// lets the user approve, reject and edit them. It returns false when the
+// user aborted.
+func (r *Repo) ReviewReword(ctx context.Context, commits []RewordCommit, opts Options) (bool, error) {
+	editor := textarea.New()
+	editor.ShowLineNumbers = false
+	editor.SetHeight(6)
+
+	m := rewordModel{repo: r, opts: opts, commits: commits, state: "list", editor: editor}
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx))
+	final, err := p.Run()
+	if err != nil {
+		return false, err
+	}
+
+	m = final.(rewordModel)
+	copy(commits, m.commits)
+	return m.accept, nil
+}
@@ -0,0 +1,91 @@
+package src_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestApplyReword(t *testing.T) {
+	repo := newTestRepo(t)
+	dir := repo.Path
+	first := commitFile(t, dir, "a.txt", "a\n", "add a")
+	commitFile(t, dir, "b.txt", "b\n", "add b")
+	commitFile(t, dir, "c.txt", "c\n", "add c",
+		"GIT_AUTHOR_NAME=Other", "GIT_AUTHOR_EMAIL=other@example.com", "GIT_AUTHOR_DATE=1700000000 +0200")
+
+	before := strings.Split(git(t, dir, "log", "--format=%T %an %ae %ad", "--date=raw", "HEAD~3..HEAD"), "\n")
+
+	commits, err := repo.RewordCommits("HEAD~3..HEAD", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 3 || commits[0].OldMessage != "add a" {
+		t.Fatalf("RewordCommits() = %+v", commits)
+	}
+	commits[1].Message, commits[1].Approved = "feat: add b", true
+	commits[2].Message, commits[2].Approved = "feat: add c", false
+
+	rewritten, err := repo.ApplyReword(commits, src.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewritten != 1 {
+		t.Errorf("ApplyReword() = %d, want 1", rewritten)
+	}
+
+	if got := git(t, dir, "log", "--format=%s", "HEAD~3..HEAD"); got != "add c\nfeat: add b\nadd a" {
+		t.Errorf("subjects after reword:\n%s", got)
+	}
+	if got := git(t, dir, "rev-parse", "HEAD~2"); got != first {
+		t.Errorf("the commit before the reworded one was rewritten: %s, want %s", got, first)
+	}
+	after := strings.Split(git(t, dir, "log", "--format=%T %an %ae %ad", "--date=raw", "HEAD~3..HEAD"), "\n")
+	for i := range before {
+		if after[i] != before[i] {
+			t.Errorf("tree or author changed: %q, want %q", after[i], before[i])
+		}
+	}
+	if status := git(t, dir, "status", "--porcelain"); status != "" {
+		t.Errorf("working tree changed:\n%s", status)
+	}
+}
+
+func TestRewordCommitsRefuses(t *testing.T) {
+	t.Run("pushed", func(t *testing.T) {
+		repo := newTestRepo(t)
+		remote := t.TempDir()
+		git(t, remote, "init", "-q", "--bare")
+		git(t, repo.Path, "remote", "add", "origin", remote)
+		git(t, repo.Path, "push", "-q", "origin", "main")
+
+		_, err := repo.RewordCommits("HEAD", false)
+		if err == nil || !strings.Contains(err.Error(), "already pushed") {
+			t.Errorf("RewordCommits() error = %v, want already pushed", err)
+		}
+		if _, err := repo.RewordCommits("HEAD", true); err != nil {
+			t.Errorf("RewordCommits() with force: %v", err)
+		}
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		repo := newTestRepo(t)
+		dir := repo.Path
+		git(t, dir, "checkout", "-q", "-b", "topic")
+		commitFile(t, dir, "topic.txt", "topic\n", "add topic")
+		git(t, dir, "checkout", "-q", "main")
+		commitFile(t, dir, "main.txt", "main\n", "add main")
+		git(t, dir, "merge", "-q", "--no-ff", "-m", "merge topic", "topic")
+		commitFile(t, dir, "after.txt", "after\n", "add after")
+
+		_, err := repo.RewordCommits("HEAD~3..HEAD", false)
+		if err == nil || !strings.Contains(err.Error(), "merge") {
+			t.Errorf("RewordCommits() error = %v, want a merge refusal", err)
+		}
+		// Commits after the merge can still be reworded
+		if _, err := repo.RewordCommits("HEAD", false); err != nil {
+			t.Errorf("RewordCommits(HEAD): %v", err)
+		}
+	})
+}
@@ -0,0 +1,317 @@
+package src
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AllowlistFile lists, at the repository root, what the secret scanner
+// should let through. Each line is one of:
+//
+//	path:<glob>     files never scanned, such as test fixtures
+//	rule:<name>     a rule turned off, such as "high entropy string"
+//	match:<regex>   findings whose text matches are ignored
+//
+// A line without a prefix is a path glob. Lines starting with # are comments.
+const AllowlistFile = ".zvezda-allowlist"
+
+// Finding is a likely secret in the staged changes
+type Finding struct {
+	File  string
+	Line  int // line in the new file, 0 for filename findings
+	Rule  string
+	Match string // redacted
+}
+
+func (f Finding) String() string {
+	if f.Line == 0 {
+		return fmt.Sprintf("%s: %s", f.File, f.Rule)
+	}
+	return fmt.Sprintf("%s:%d: %s (%s)", f.File, f.Line, f.Rule, f.Match)
+}
+
+// SecretsError blocks a commit that would add secrets
+type SecretsError struct {
+	Findings []Finding
+}
+
+func (e *SecretsError) Error() string {
+	lines := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		lines[i] = "  " + f.String()
+	}
+	return fmt.Sprintf("refusing to commit %d possible secrets, unstage them or add them to %s:\n%s",
+		len(e.Findings), AllowlistFile, strings.Join(lines, "\n"))
+}
+
+// Files returns the files holding the findings, in the order found
+func (e *SecretsError) Files() []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, f := range e.Findings {
+		if !seen[f.File] {
+			seen[f.File] = true
+			files = append(files, f.File)
+		}
+	}
+	return files
+}
+
+// Allowlist is the parsed AllowlistFile
+type Allowlist struct {
+	Paths   []string
+	Rules   []string
+	Matches []*regexp.Regexp
+}
+
+type secretRule struct {
+	name  string
+	regex *regexp.Regexp
+}
+
+var secretRules = []secretRule{
+	{"AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA)[0-9A-Z]{16}\b`)},
+	{"AWS secret key", regexp.MustCompile(`(?i)aws.{0,20}(?:secret|private).{0,20}['"=:]\s*['"]?[0-9A-Za-z/+]{40}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"Slack webhook", regexp.MustCompile(`https://hooks\.slack\.com/services/T[A-Za-z0-9]+/B[A-Za-z0-9]+/[A-Za-z0-9]+`)},
+	{"private key", regexp.MustCompile(`-----BEGIN (?:RSA |DSA |EC |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY( BLOCK)?-----`)},
+}
+
+// forbiddenFiles are file names that hold credentials, matched on the base
+// name
+var forbiddenFiles = []string{
+	".env", ".env.*", "*.pem", "*.key", "*.p12", "*.pfx", "*.jks", "*.keystore",
+	"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519", ".netrc", ".pypirc", ".npmrc",
+	"credentials.json", "service-account*.json",
+}
+
+// exampleSuffixes mark env files that only document the variables
+var exampleSuffixes = []string{".example", ".sample", ".template", ".dist"}
+
+// Quoted strings and assignment values long enough to be keys
+var candidateRegex = regexp.MustCompile(`['"]([A-Za-z0-9+/=_\-]{20,})['"]|[=:]\s*([A-Za-z0-9+/=_\-]{20,})\b`)
+
+var hexRegex = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+
+// Lockfiles are full of hashes that look like secrets
+var entropyExempt = map[string]bool{
+	"go.sum": true, "package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true,
+	"Cargo.lock": true, "poetry.lock": true, "composer.lock": true, "Gemfile.lock": true,
+}
+
+// LoadAllowlist reads the allowlist at the repository root, if any
+func LoadAllowlist(root string) (Allowlist, error) {
+	var allow Allowlist
+	data, err := os.ReadFile(filepath.Join(root, AllowlistFile))
+	if os.IsNotExist(err) {
+		return allow, nil
+	}
+	if err != nil {
+		return allow, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "rule:"):
+			allow.Rules = append(allow.Rules, strings.TrimSpace(strings.TrimPrefix(line, "rule:")))
+		case strings.HasPrefix(line, "match:"):
+			re, err := regexp.Compile(strings.TrimSpace(strings.TrimPrefix(line, "match:")))
+			if err != nil {
+				return allow, fmt.Errorf("%s: %w", AllowlistFile, err)
+			}
+			allow.Matches = append(allow.Matches, re)
+		default:
+			allow.Paths = append(allow.Paths, strings.TrimSpace(strings.TrimPrefix(line, "path:")))
+		}
+	}
+	return allow, nil
+}
+
+func (a Allowlist) allowsPath(file string) bool {
+	for _, glob := range a.Paths {
+		if MatchGlob(glob, file) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Allowlist) allows(rule, match string) bool {
+	for _, r := range a.Rules {
+		if strings.EqualFold(r, rule) {
+			return true
+		}
+	}
+	for _, re := range a.Matches {
+		if re.MatchString(match) {
+			return true
+		}
+	}
+	return false
+}
+
+// entropy is the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var h float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// randomEntropy estimates the entropy of a random string of n characters
+// from an alphabet of k: about log2 of how many distinct characters it
+// holds, which grows with the length
+func randomEntropy(n, k int) float64 {
+	distinct := float64(k) * (1 - math.Pow(1-1/float64(k), float64(n)))
+	return math.Log2(distinct)
+}
+
+// highEntropy reports strings random enough to be keys: hex strings of 32
+// characters or more above 3 bits, and other strings mixing letters and
+// digits within 0.35 bits of a random string of the same length and
+// alphabet. A fixed threshold would miss short keys, whose entropy cannot
+// exceed log2 of their length.
+func highEntropy(s string) bool {
+	if hexRegex.MatchString(s) {
+		return len(s) >= 32 && entropy(s) > 3.0
+	}
+	if !strings.ContainsAny(s, "0123456789") {
+		return false // identifiers and words
+	}
+	alphabet := 62
+	if strings.ContainsAny(s, "+/=_-") {
+		alphabet = 64
+	}
+	return entropy(s) > randomEntropy(len(s), alphabet)-0.35
+}
+
+// redact keeps the first and last characters of a secret
+func redact(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", min(len(s)-8, 12)) + s[len(s)-4:]
+}
+
+func isForbiddenFile(file string) bool {
+	base := path.Base(file)
+	for _, suffix := range exampleSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			return false
+		}
+	}
+	for _, pattern := range forbiddenFiles {
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ScanDiff looks at the files and added lines of a diff for forbidden file
+// names, known token formats and high entropy strings
+func ScanDiff(diff string, allow Allowlist) []Finding {
+	var findings []Finding
+
+	for _, file := range ParseDiff(diff) {
+		name := file.Path()
+		if file.Status == "deleted" || allow.allowsPath(name) {
+			continue
+		}
+
+		if isForbiddenFile(name) && !allow.allows("forbidden file", name) {
+			findings = append(findings, Finding{File: name, Rule: "forbidden file"})
+		}
+
+		for _, h := range file.Hunks {
+			line := hunkStart(h.Header)
+			for _, l := range h.Lines {
+				if strings.HasPrefix(l, "-") || strings.HasPrefix(l, "\\") {
+					continue
+				}
+				if strings.HasPrefix(l, "+") {
+					findings = append(findings, scanLine(name, line, l[1:], allow)...)
+				}
+				line++
+			}
+		}
+	}
+	return findings
+}
+
+func scanLine(file string, line int, text string, allow Allowlist) []Finding {
+	var findings []Finding
+	found := false
+	for _, rule := range secretRules {
+		for _, m := range rule.regex.FindAllString(text, -1) {
+			if allow.allows(rule.name, m) {
+				continue
+			}
+			findings = append(findings, Finding{File: file, Line: line, Rule: rule.name, Match: redact(m)})
+			found = true
+		}
+	}
+	if found || entropyExempt[path.Base(file)] {
+		return findings
+	}
+
+	for _, m := range candidateRegex.FindAllStringSubmatch(text, -1) {
+		candidate := m[1] + m[2]
+		if highEntropy(candidate) && !allow.allows("high entropy string", candidate) {
+			findings = append(findings, Finding{File: file, Line: line, Rule: "high entropy string", Match: redact(candidate)})
+		}
+	}
+	return findings
+}
+
+var hunkStartRegex = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)`)
+
+// hunkStart returns the first line number of the new side of a hunk
+func hunkStart(header string) int {
+	m := hunkStartRegex.FindStringSubmatch(header)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
+// ScanStaged scans what is about to be committed, honouring the allowlist
+// of the repository. It returns a *SecretsError when something was found.
+func (r *Repo) ScanStaged() error {
+	diff, err := r.StagedDiff()
+	if err != nil {
+		return err
+	}
+	return r.scanDiff(diff)
+}
+
+// scanDiff scans a diff with the allowlist of the repository
+func (r *Repo) scanDiff(diff string) error {
+	allow, err := LoadAllowlist(r.Path)
+	if err != nil {
+		return err
+	}
+	if findings := ScanDiff(diff, allow); len(findings) > 0 {
+		return &SecretsError{Findings: findings}
+	}
+	return nil
+}
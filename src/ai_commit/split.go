@@ -0,0 +1,682 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// CommitGroup is one commit proposed by the split mode. The hunks of a
+// modified file may be spread over several groups.
+type CommitGroup struct {
+	Name     string   // what the changes have in common
+	Files    []string // the files the commit touches
+	Message  string
+	Approved bool
+
+	units []splitUnit
+}
+
+// splitUnit is the smallest change the split mode moves around: a hunk of
+// a modified file, or a whole file when it is added, deleted, renamed,
+// binary, changes mode or is untracked
+type splitUnit struct {
+	index     int // position in the diff, keeps the hunks of a file in order
+	file      FileDiff
+	hunk      int // index in file.Hunks, -1 for the whole file
+	untracked bool
+	words     map[string]bool
+}
+
+// paths returns the paths git add must stage for a whole file unit
+func (u splitUnit) paths() []string {
+	if u.file.Status == "renamed" && u.file.OldPath != u.file.NewPath {
+		return []string{u.file.OldPath, u.file.NewPath}
+	}
+	return []string{u.file.Path()}
+}
+
+var buildFiles = map[string]bool{
+	"go.mod": true, "go.sum": true, "go.work": true, "package.json": true,
+	"package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true,
+	"Cargo.toml": true, "Cargo.lock": true, "pyproject.toml": true,
+	"requirements.txt": true, "poetry.lock": true, "Makefile": true,
+	"Dockerfile": true, "pom.xml": true, "build.gradle": true,
+}
+
+// groupKey decides which cluster a file starts in
+func groupKey(file string, conventions Conventions, modules []Module) string {
+	base := path.Base(file)
+	switch {
+	case buildFiles[base] || strings.HasPrefix(file, ".github/"):
+		return "build"
+	case strings.HasSuffix(base, ".md") || strings.HasPrefix(file, "docs/"):
+		return "docs"
+	}
+
+	if scope, ok := conventions.ScopeFor([]string{file}); ok {
+		return scope
+	}
+	if m, ok := OwningModule(modules, file); ok && m.Dir != "" && m.Dir != "." {
+		return m.Name
+	}
+
+	dir := path.Dir(file)
+	if strings.HasSuffix(file, ".go") {
+		return dir // the Go package
+	}
+	parts := strings.Split(dir, "/")
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, "/")
+}
+
+var identifierRegex = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]{3,}`)
+
+// identifiers returns the words of the changed lines of a diff
+func identifiers(diff string) map[string]bool {
+	words := make(map[string]bool)
+	for _, line := range strings.Split(diff, "\n") {
+		if (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")) &&
+			!strings.HasPrefix(line, "+++") && !strings.HasPrefix(line, "---") {
+			for _, w := range identifierRegex.FindAllString(line, -1) {
+				words[w] = true
+			}
+		}
+	}
+	return words
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// overlap returns the share of the words of a that b contains too
+func overlap(a, b map[string]bool) float64 {
+	if len(a) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a))
+}
+
+// headTree returns HEAD, or the empty tree before the first commit
+func (r *Repo) headTree() (string, error) {
+	if _, err := r.Git("rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		return "HEAD", nil
+	}
+	out, err := r.Git("hash-object", "-t", "tree", "/dev/null")
+	return strings.TrimSpace(out), err
+}
+
+// splitUnits parses every change in the working tree, staged or not, into
+// the units the split mode groups
+func (r *Repo) splitUnits() ([]splitUnit, error) {
+	base, err := r.headTree()
+	if err != nil {
+		return nil, err
+	}
+	diff, err := r.Git("diff", base)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []splitUnit
+	for _, f := range ParseDiff(diff) {
+		if f.Status == "modified" && !f.Binary && f.OldMode == "" && len(f.Hunks) > 0 {
+			for j, h := range f.Hunks {
+				units = append(units, splitUnit{index: len(units), file: f, hunk: j,
+					words: identifiers(strings.Join(h.Lines, "\n"))})
+			}
+			continue
+		}
+		var lines []string
+		for _, h := range f.Hunks {
+			lines = append(lines, h.Lines...)
+		}
+		units = append(units, splitUnit{index: len(units), file: f, hunk: -1,
+			words: identifiers(strings.Join(lines, "\n"))})
+	}
+
+	untracked, _ := r.UntrackedFiles()
+	for _, file := range untracked {
+		// Untracked files have no diff, their content counts as added lines
+		data, _ := os.ReadFile(r.Abs(file))
+		content := "+" + strings.ReplaceAll(string(data), "\n", "\n+")
+		units = append(units, splitUnit{index: len(units), file: FileDiff{NewPath: file, Status: "added"},
+			hunk: -1, untracked: true, words: identifiers(content)})
+	}
+	return units, nil
+}
+
+// ProposeSplit clusters every change in the working tree, staged or not,
+// into groups meant to become separate commits. Hunks of modified files
+// and whole other files start grouped by the conventions scope, workspace
+// module, Go package or directory of their file. A hunk then moves to
+// another group when it clearly shares more identifiers with it than with
+// its own, and groups of a single file are merged into the group whose
+// changes share the most identifiers with them. Build files come first,
+// docs last.
+func (r *Repo) ProposeSplit() ([]CommitGroup, error) {
+	units, err := r.splitUnits()
+	if err != nil {
+		return nil, err
+	}
+	conventions, err := LoadConventions(r.Path)
+	if err != nil {
+		return nil, err
+	}
+	modules := DiscoverModules(r.Path)
+
+	keys := make([]string, len(units))
+	for i, u := range units {
+		keys[i] = groupKey(u.file.Path(), conventions, modules)
+	}
+
+	// The words of each group without the unit i, from the initial groups
+	// so that the order of the moves does not matter
+	groupWords := func(key string, i int) map[string]bool {
+		words := make(map[string]bool)
+		for j, u := range units {
+			if j != i && keys[j] == key {
+				for w := range u.words {
+					words[w] = true
+				}
+			}
+		}
+		return words
+	}
+	initial := append([]string{}, keys...)
+	names := sortedUnique(initial)
+	for i, u := range units {
+		if u.hunk < 0 || initial[i] == "build" || initial[i] == "docs" {
+			continue
+		}
+		own := overlap(u.words, groupWords(initial[i], i))
+		best, bestScore := "", max(0.5, own+0.25)
+		for _, name := range names {
+			if name == initial[i] || name == "build" || name == "docs" {
+				continue
+			}
+			if score := overlap(u.words, groupWords(name, i)); score >= bestScore {
+				best, bestScore = name, score
+			}
+		}
+		if best != "" {
+			keys[i] = best
+		}
+	}
+
+	groups := make(map[string]*CommitGroup)
+	words := make(map[string]map[string]bool)
+	for i, u := range units {
+		g, ok := groups[keys[i]]
+		if !ok {
+			g = &CommitGroup{Name: keys[i], Approved: true}
+			groups[keys[i]] = g
+			words[keys[i]] = make(map[string]bool)
+		}
+		g.units = append(g.units, u)
+		for w := range u.words {
+			words[keys[i]][w] = true
+		}
+	}
+
+	// Merge groups of a single file into a related group, in the order of
+	// their names so the result does not depend on map iteration
+	names = sortedUnique(keys)
+	for _, key := range names {
+		g, ok := groups[key]
+		if !ok || len(unitFiles(g.units)) != 1 || key == "build" || key == "docs" {
+			continue
+		}
+		best, bestScore := "", 0.3
+		for _, other := range names {
+			if _, ok := groups[other]; !ok || other == key || other == "build" || other == "docs" {
+				continue
+			}
+			if score := jaccard(words[key], words[other]); score > bestScore {
+				best, bestScore = other, score
+			}
+		}
+		if best != "" {
+			groups[best].units = append(groups[best].units, g.units...)
+			for w := range words[key] {
+				words[best][w] = true
+			}
+			delete(groups, key)
+		}
+	}
+
+	var result []CommitGroup
+	for _, g := range groups {
+		sort.Slice(g.units, func(i, j int) bool { return g.units[i].index < g.units[j].index })
+		g.Files = unitFiles(g.units)
+		result = append(result, *g)
+	}
+	rank := func(name string) int {
+		switch name {
+		case "build":
+			return 0
+		case "docs":
+			return 2
+		}
+		return 1
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if rank(result[i].Name) != rank(result[j].Name) {
+			return rank(result[i].Name) < rank(result[j].Name)
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func sortedUnique(values []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// unitFiles returns the sorted files the units touch
+func unitFiles(units []splitUnit) []string {
+	var files []string
+	for _, u := range units {
+		files = append(files, u.file.Path())
+	}
+	return sortedUnique(files)
+}
+
+// diff renders the hunks of modified files of the group as a patch for git
+// apply; the other files are staged as they are with git add.
+func (g CommitGroup) diff() string {
+	var b strings.Builder
+	last := ""
+	for _, u := range g.units {
+		if u.untracked || u.hunk < 0 {
+			continue
+		}
+		if u.file.Path() != last {
+			b.WriteString(strings.Join(u.file.Header, "\n") + "\n")
+			last = u.file.Path()
+		}
+		h := u.file.Hunks[u.hunk]
+		b.WriteString(h.Header + "\n")
+		for _, line := range h.Lines {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// groupIndex fills a temporary index with HEAD plus the changes of the
+// group, the index the group is committed from. env selects it for git,
+// cleanup removes it.
+func (r *Repo) groupIndex(g CommitGroup) (env []string, cleanup func(), err error) {
+	var whole []string
+	for _, u := range g.units {
+		if u.hunk < 0 {
+			whole = append(whole, u.paths()...)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "zvezda-split-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	env = []string{"GIT_INDEX_FILE=" + filepath.Join(dir, "index")}
+
+	base, err := r.headTree()
+	if err == nil {
+		_, err = r.gitEnv(env, "", "read-tree", base)
+	}
+	if patch := g.diff(); err == nil && patch != "" {
+		_, err = r.gitEnv(env, patch, "apply", "--cached", "--recount", "-")
+	}
+	if err == nil && len(whole) > 0 {
+		_, err = r.gitEnv(env, "", append([]string{"add", "-A", "--"}, whole...)...)
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return env, cleanup, nil
+}
+
+// groupChanges describes the group to generate its message without staging
+// it. The files are read from the tree the group would commit, so hunks of
+// other groups in the same files stay out of the analysis.
+func (r *Repo) groupChanges(g CommitGroup) (Changes, error) {
+	env, cleanup, err := r.groupIndex(g)
+	if err != nil {
+		return Changes{}, err
+	}
+	defer cleanup()
+
+	base, err := r.headTree()
+	if err != nil {
+		return Changes{}, err
+	}
+	c := Changes{Base: base}
+	if c.Target, err = r.gitEnv(env, "", "write-tree"); err != nil {
+		return c, err
+	}
+	c.Target = strings.TrimSpace(c.Target)
+	if c.Staged, err = r.gitEnv(env, "", "diff", "--staged", "--no-color"); err != nil {
+		return c, err
+	}
+	c.StagedFiles, err = r.gitLines("diff", "--name-only", base, c.Target)
+	return c, err
+}
+
+// GenerateSplitMessages generates the message of each group from
+// GenerateCommitPrompt. The index is not touched. onProgress, if set, is
+// called before each group.
+func (r *Repo) GenerateSplitMessages(ctx context.Context, groups []CommitGroup, opts Options, onProgress func(i int)) error {
+	for i := range groups {
+		if onProgress != nil {
+			onProgress(i)
+		}
+		changes, err := r.groupChanges(groups[i])
+		if err != nil {
+			return err
+		}
+		prompt, err := r.GenerateCommitPrompt(changes, opts)
+		if err != nil {
+			return err
+		}
+		if prompt == "" {
+			groups[i].Approved = false
+			continue
+		}
+		result, err := r.GenerateCommitMessage(ctx, changes, opts, prompt, Stream{})
+		if err != nil {
+			return err
+		}
+		groups[i].Message = result.Message
+	}
+	return nil
+}
+
+// CreateSplitCommits creates one commit per approved group, in order. Each
+// commit is built in a temporary index, so what the user staged stays
+// staged, and changes of groups that were not approved stay where they
+// were.
+func (r *Repo) CreateSplitCommits(groups []CommitGroup, opts Options) (int, error) {
+	created := 0
+	for _, g := range groups {
+		if !g.Approved || strings.TrimSpace(g.Message) == "" {
+			continue
+		}
+		if err := r.commitGroup(g, opts); err != nil {
+			return created, err
+		}
+		created++
+	}
+	return created, nil
+}
+
+// commitGroup commits the changes of the group on top of HEAD from a
+// temporary index, then updates the index of the user, see syncIndex
+func (r *Repo) commitGroup(g CommitGroup, opts Options) error {
+	staged, err := r.gitLines(append([]string{"diff", "--staged", "--name-only", "--"}, g.Files...)...)
+	if err != nil {
+		return err
+	}
+
+	env, cleanup, err := r.groupIndex(g)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	diff, err := r.gitEnv(env, "", "diff", "--staged")
+	if err != nil {
+		return err
+	}
+	if err := r.scanDiff(diff); err != nil {
+		return err
+	}
+	if err := r.commit(env, g.Message, opts, "-q"); err != nil {
+		return err
+	}
+	return r.syncIndex(g, staged)
+}
+
+// syncIndex updates the index of the user after the group was committed.
+// Files without staged changes, and whole files, now match HEAD. Files
+// with staged hunks keep them, and get the committed hunks on top unless
+// those were staged already.
+func (r *Repo) syncIndex(g CommitGroup, staged []string) error {
+	isStaged := make(map[string]bool)
+	for _, file := range staged {
+		isStaged[file] = true
+	}
+
+	var reset []string
+	partial := make(map[string][]splitUnit)
+	for _, u := range g.units {
+		if u.hunk >= 0 && isStaged[u.file.Path()] {
+			partial[u.file.Path()] = append(partial[u.file.Path()], u)
+		} else {
+			reset = append(reset, u.paths()...)
+		}
+	}
+
+	for _, file := range sortedUnique(staged) {
+		units, ok := partial[file]
+		if !ok {
+			continue
+		}
+		patch := CommitGroup{units: units}.diff()
+		if _, err := r.GitInput(patch, "apply", "--cached", "--recount", "--reverse", "--check", "-"); err == nil {
+			continue // staged already
+		}
+		// When the staged changes conflict with the hunks, they are kept
+		// as they are
+		r.GitInput(patch, "apply", "--cached", "--recount", "-")
+	}
+
+	if len(reset) == 0 {
+		return nil
+	}
+	_, err := r.Git(append([]string{"reset", "-q", "--"}, sortedUnique(reset)...)...)
+	return err
+}
+
+type splitModel struct {
+	repo    *Repo
+	opts    Options
+	groups  []CommitGroup
+	cursor  int
+	state   string // "list", "editing", "confirm", "done"
+	editor  textarea.Model
+	note    string
+	push    bool
+	accept  bool
+	windowW int
+}
+
+func (m splitModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m splitModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.windowW = msg.Width
+		m.editor.SetWidth(max(20, msg.Width-6))
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			m.state = "done"
+			return m, tea.Quit
+		}
+
+		switch m.state {
+		case "list":
+			switch msg.String() {
+			case "up", "k":
+				if m.cursor > 0 {
+					m.cursor--
+				}
+			case "down", "j":
+				if m.cursor < len(m.groups)-1 {
+					m.cursor++
+				}
+			case " ", "x":
+				m.groups[m.cursor].Approved = !m.groups[m.cursor].Approved
+			case "e":
+				m.state = "editing"
+				m.editor.SetValue(m.groups[m.cursor].Message)
+				return m, m.editor.Focus()
+			case "enter":
+				m.state = "confirm"
+			case "q", "esc":
+				m.state = "done"
+				return m, tea.Quit
+			}
+
+		case "editing":
+			switch msg.String() {
+			case "ctrl+s":
+				message := strings.TrimSpace(m.editor.Value())
+				m.groups[m.cursor].Message = message
+				m.editor.Blur()
+				m.state = "list"
+				rules, _ := m.repo.Rules(m.opts)
+				if violations := Validate(message, rules); len(violations) > 0 {
+					m.note = "Warning: " + strings.Join(violations, "; ")
+				} else {
+					m.note = ""
+				}
+				return m, nil
+			case "esc":
+				m.editor.Blur()
+				m.state = "list"
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.editor, cmd = m.editor.Update(msg)
+			return m, cmd
+
+		case "confirm":
+			switch msg.String() {
+			case "y", "p":
+				m.accept, m.push = true, true
+				m.state = "done"
+				return m, tea.Quit
+			case "c":
+				m.accept = true
+				m.state = "done"
+				return m, tea.Quit
+			case "n", "esc":
+				m.state = "list"
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m splitModel) View() string {
+	if m.state == "done" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(reviewTitleStyle.Render(" Split into commits") + "\n\n")
+
+	approved := 0
+	for i, g := range m.groups {
+		cursor := "  "
+		if i == m.cursor {
+			cursor = "> "
+		}
+		check := "[ ]"
+		if g.Approved {
+			check = reviewAddedStyle.Render("[x]")
+			approved++
+		}
+		header := strings.SplitN(g.Message, "\n", 2)[0]
+		b.WriteString(fmt.Sprintf("%s%s %d. %s %s\n", cursor, check, i+1, header,
+			reviewHelpStyle.Render(fmt.Sprintf("(%s, %d files)", g.Name, len(g.Files)))))
+	}
+
+	if len(m.groups) > 0 {
+		g := m.groups[m.cursor]
+		b.WriteString("\n")
+		if m.state == "editing" {
+			b.WriteString(reviewBoxStyle.Render(m.editor.View()) + "\n")
+		} else {
+			b.WriteString(reviewBoxStyle.Width(max(20, m.windowW-4)).Render(g.Message) + "\n")
+		}
+		for _, file := range g.Files {
+			b.WriteString("  " + file + "\n")
+		}
+	}
+	if m.note != "" {
+		b.WriteString(reviewWarningStyle.Render(m.note) + "\n")
+	}
+	b.WriteString("\n")
+
+	switch m.state {
+	case "list":
+		b.WriteString(reviewHelpStyle.Render(fmt.Sprintf("%d approved • space toggle • e edit message • enter create commits • q abort", approved)))
+	case "editing":
+		b.WriteString(reviewHelpStyle.Render("ctrl+s save • esc cancel"))
+	case "confirm":
+		b.WriteString(reviewWarningStyle.Render(fmt.Sprintf("Create %d commits and push? y push • c commit only • n back", approved)))
+	}
+	return b.String()
+}
+
+// ReviewSplit lets the user approve, reject and edit the proposed commits.
+// It returns false when the user aborted.
+func (r *Repo) ReviewSplit(ctx context.Context, groups []CommitGroup, opts Options) (accepted, push bool, err error) {
+	editor := textarea.New()
+	editor.ShowLineNumbers = false
+	editor.SetHeight(6)
+
+	m := splitModel{repo: r, opts: opts, groups: groups, state: "list", editor: editor}
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx))
+	final, err := p.Run()
+	if err != nil {
+		return false, false, err
+	}
+
+	m = final.(splitModel)
+	copy(groups, m.groups)
+	return m.accept, m.push, nil
+}
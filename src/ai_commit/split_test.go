@@ -0,0 +1,185 @@
+package src_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+const mainBefore = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("start")
+}
+
+// one
+// two
+// three
+// four
+// five
+// six
+// seven
+// eight
+
+func helper() {
+	fmt.Println("helper")
+}
+`
+
+// newSplitRepo commits a small project and changes it: a hunk of main.go
+// uses a function added to the store package, the other one does not
+func newSplitRepo(t *testing.T) *src.Repo {
+	t.Helper()
+	repo := newTestRepo(t)
+	dir := repo.Path
+	commitFile(t, dir, "cmd/app/main.go", mainBefore, "add app")
+	commitFile(t, dir, "pkg/store/store.go", "package store\n", "add store")
+
+	mainAfter := strings.Replace(mainBefore, `fmt.Println("start")`, "fmt.Println(\"start\")\n\tstore.LoadWidgetCatalog()", 1)
+	mainAfter = strings.Replace(mainAfter, `fmt.Println("helper")`, "fmt.Println(\"helper\")\n\tfmt.Println(\"verbose logging\")", 1)
+	writeFile(t, dir, "cmd/app/main.go", mainAfter)
+	writeFile(t, dir, "pkg/store/store.go", "package store\n\nfunc LoadWidgetCatalog() {}\n")
+	writeFile(t, dir, "pkg/store/cache.go", "package store\n\nvar catalogCache = map[string]int{}\n")
+	writeFile(t, dir, "README.md", "# test\n\nMore docs.\n")
+	writeFile(t, dir, "web/panel.js", "renderWidgetPanel(widgetState)\n")
+	writeFile(t, dir, "lib/panel.py", "renderWidgetPanel(widgetState)\n")
+	return repo
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProposeSplit(t *testing.T) {
+	repo := newSplitRepo(t)
+
+	want := "[cmd/app [cmd/app/main.go]] [pkg/store [cmd/app/main.go pkg/store/cache.go pkg/store/store.go]] " +
+		"[web [lib/panel.py web/panel.js]] [docs [README.md]]"
+	for i := 0; i < 5; i++ {
+		groups, err := repo.ProposeSplit()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, g := range groups {
+			got = append(got, fmt.Sprintf("[%s %s]", g.Name, g.Files))
+		}
+		if strings.Join(got, " ") != want {
+			t.Fatalf("ProposeSplit() = %s\nwant %s", strings.Join(got, " "), want)
+		}
+	}
+}
+
+func TestCreateSplitCommits(t *testing.T) {
+	tests := []struct {
+		name   string
+		staged []string
+	}{
+		{"unrelated file staged", []string{"README.md"}},
+		{"split file staged", []string{"README.md", "cmd/app/main.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newSplitRepo(t)
+			dir := repo.Path
+			git(t, dir, append([]string{"add", "--"}, tt.staged...)...)
+
+			groups, err := repo.ProposeSplit()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range groups {
+				groups[i].Message = "chore: update " + groups[i].Name
+				groups[i].Approved = groups[i].Name == "cmd/app" || groups[i].Name == "pkg/store"
+			}
+
+			created, err := repo.CreateSplitCommits(groups, src.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created != 2 {
+				t.Errorf("CreateSplitCommits() = %d, want 2", created)
+			}
+
+			app := git(t, dir, "show", "HEAD~1:cmd/app/main.go")
+			if !strings.Contains(app, "verbose logging") || strings.Contains(app, "LoadWidgetCatalog") {
+				t.Errorf("the cmd/app commit has the wrong hunks:\n%s", app)
+			}
+			if got := git(t, dir, "show", "--name-only", "--format=", "HEAD"); got != "cmd/app/main.go\npkg/store/cache.go\npkg/store/store.go" {
+				t.Errorf("files of the pkg/store commit:\n%s", got)
+			}
+			// The staged docs stay staged, the groups left out stay in the
+			// working tree, and nothing committed shows up as changed
+			want := "M  README.md\n?? lib/\n?? web/"
+			if got := git(t, dir, "status", "--porcelain"); got != want {
+				t.Errorf("status after the split:\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestGenerateSplitMessages(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct{ Content string } `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		prompts = append(prompts, body.Messages[0].Content)
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"chore: update\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	repo := newTestRepo(t)
+	t.Setenv("ZVEZDA_PROVIDER", "openai")
+	t.Setenv("ZVEZDA_ENDPOINT", server.URL)
+	dir := repo.Path
+	// The split fixture as a library: both hunks of app.go change an
+	// exported function, only Start uses the store
+	appBefore := strings.NewReplacer("package main", "package app", "func main", "func Start", "func helper", "func Helper").Replace(mainBefore)
+	commitFile(t, dir, "app/app.go", appBefore, "add app")
+	commitFile(t, dir, "pkg/store/store.go", "package store\n", "add store")
+	appAfter := strings.Replace(appBefore, `fmt.Println("start")`, "fmt.Println(\"start\")\n\tstore.LoadWidgetCatalog()", 1)
+	appAfter = strings.Replace(appAfter, `fmt.Println("helper")`, "fmt.Println(\"helper\")\n\tfmt.Println(\"verbose logging\")", 1)
+	writeFile(t, dir, "app/app.go", appAfter)
+	writeFile(t, dir, "pkg/store/store.go", "package store\n\nfunc LoadWidgetCatalog() {}\n")
+
+	groups, err := repo.ProposeSplit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].Name != "app" {
+		t.Fatalf("ProposeSplit() = %+v, want app and pkg/store", groups)
+	}
+	if err := repo.GenerateSplitMessages(context.Background(), groups, src.Options{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(prompts) != 2 {
+		t.Fatalf("%d prompts, want 2", len(prompts))
+	}
+	if !strings.Contains(prompts[0], "modified func Helper") || strings.Contains(prompts[0], "modified func Start") {
+		t.Errorf("the app prompt analyses hunks of the other group:\n%s", prompts[0])
+	}
+	if !strings.Contains(prompts[1], "modified func Start") || strings.Contains(prompts[1], "modified func Helper") {
+		t.Errorf("the pkg/store prompt analyses hunks of the other group:\n%s", prompts[1])
+	}
+}
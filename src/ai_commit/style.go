@@ -0,0 +1,174 @@
+package src
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// StyleProfile describes how the commit subjects of a repository are
+// written, so generated messages can match them
+type StyleProfile struct {
+	Examples     []string // sampled subjects, newest first
+	Conventional int      // subjects in the <type>(<scope>): form
+	Gitmoji      int      // subjects starting with an emoji
+	Capitalized  int      // subjects whose first word after any prefix is capitalized
+	MedianLength int
+	LongLength   int            // 90th percentile of the header length
+	Types        map[string]int // conventional types and how often they are used
+	Scopes       map[string]int // conventional scopes and how often they are used
+}
+
+// Bots and tools whose commits say nothing about the voice of the team
+var botAuthors = []string{"[bot]", "dependabot", "renovate", "github-actions", "greenkeeper", "snyk-bot", "pre-commit-ci"}
+
+// Subjects git and editors write on their own
+var generatedPrefixes = []string{"Merge ", "Revert \"", "fixup!", "squash!", "amend!", "Initial commit", "WIP", "wip"}
+
+func isBotAuthor(name, email string) bool {
+	author := strings.ToLower(name + " " + email)
+	for _, bot := range botAuthors {
+		if strings.Contains(author, bot) {
+			return true
+		}
+	}
+	return false
+}
+
+// wellFormedSubject rejects generated subjects and the ones too short or
+// too long to be worth imitating
+func wellFormedSubject(subject string) bool {
+	for _, prefix := range generatedPrefixes {
+		if strings.HasPrefix(subject, prefix) {
+			return false
+		}
+	}
+	n := len([]rune(subject))
+	return n >= 10 && n <= 100 && len(strings.Fields(subject)) >= 2
+}
+
+// RecentSubjects returns up to n well-formed subjects of the latest
+// commits, newest first, leaving out merges and commits made by bots
+func (r *Repo) RecentSubjects(n int) []string {
+	// Read more than needed, some commits are filtered out
+	lines, _ := r.gitLines("log", "--no-merges", fmt.Sprintf("-%d", 5*n), "--format=%s%x1f%an%x1f%ae")
+
+	var subjects []string
+	for _, line := range lines {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 3 || isBotAuthor(fields[1], fields[2]) {
+			continue
+		}
+		subject := strings.TrimSpace(fields[0])
+		if !wellFormedSubject(subject) {
+			continue
+		}
+		subjects = append(subjects, subject)
+		if len(subjects) == n {
+			break
+		}
+	}
+	return subjects
+}
+
+// AnalyzeStyle computes the style statistics of commit subjects
+func AnalyzeStyle(subjects []string) StyleProfile {
+	p := StyleProfile{Examples: subjects, Types: make(map[string]int), Scopes: make(map[string]int)}
+	if len(subjects) == 0 {
+		return p
+	}
+
+	var lengths []int
+	for _, subject := range subjects {
+		lengths = append(lengths, len([]rune(subject)))
+
+		text := subject
+		if m := headerRegex.FindStringSubmatch(subject); m != nil {
+			p.Conventional++
+			p.Types[m[1]]++
+			if m[2] != "" {
+				p.Scopes[m[2]]++
+			}
+			text = m[4]
+		} else if m := gitmojiHeaderRegex.FindStringSubmatch(subject); m != nil {
+			p.Gitmoji++
+			text = m[2]
+		}
+		if first := []rune(strings.TrimSpace(text)); len(first) > 0 && unicode.IsUpper(first[0]) {
+			p.Capitalized++
+		}
+	}
+
+	sort.Ints(lengths)
+	p.MedianLength = lengths[len(lengths)/2]
+	p.LongLength = lengths[len(lengths)*9/10]
+	return p
+}
+
+// Mostly reports whether count is a clear majority of the samples
+func (p StyleProfile) Mostly(count int) bool {
+	return len(p.Examples) > 0 && count*4 >= len(p.Examples)*3
+}
+
+// Describe summarizes the statistics for the prompt
+func (p StyleProfile) Describe() string {
+	total := len(p.Examples)
+	if total == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Style of the last %d commits in this repository, follow it:\n", total)
+	switch {
+	case p.Mostly(p.Conventional):
+		fmt.Fprintf(&b, "- %d of %d are conventional commits, the most used types are %s\n", p.Conventional, total, strings.Join(topKeys(p.Types, 5), ", "))
+	case p.Mostly(p.Gitmoji):
+		fmt.Fprintf(&b, "- %d of %d start with a gitmoji\n", p.Gitmoji, total)
+	default:
+		fmt.Fprintf(&b, "- no single format: %d of %d are conventional commits, %d use a gitmoji\n", p.Conventional, total, p.Gitmoji)
+	}
+	switch {
+	case p.Mostly(p.Capitalized):
+		fmt.Fprintf(&b, "- subjects start with a capital letter (%d of %d)\n", p.Capitalized, total)
+	case p.Mostly(total - p.Capitalized):
+		fmt.Fprintf(&b, "- subjects start with a lowercase letter (%d of %d)\n", total-p.Capitalized, total)
+	}
+	fmt.Fprintf(&b, "- headers are usually around %d characters and rarely over %d\n", p.MedianLength, p.LongLength)
+	if len(p.Scopes) > 0 {
+		fmt.Fprintf(&b, "- scopes in use: %s\n", strings.Join(topKeys(p.Scopes, 10), ", "))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// topKeys returns the n most frequent keys, ties in alphabetical order
+func topKeys(counts map[string]int, n int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// Style learns the style of the repository from its history when
+// Options.LearnStyle or learn_style in the config is set, or returns nil
+func (r *Repo) Style(opts Options) *StyleProfile {
+	config, _ := LoadConfig()
+	if !opts.LearnStyle && !config.LearnStyle {
+		return nil
+	}
+	profile := AnalyzeStyle(r.RecentSubjects(config.StyleSamples))
+	if len(profile.Examples) == 0 {
+		return nil
+	}
+	return &profile
+}
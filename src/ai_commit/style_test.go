@@ -0,0 +1,39 @@
+package src_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestAnalyzeStyle(t *testing.T) {
+	p := src.AnalyzeStyle([]string{
+		"feat(api): Add user endpoint",
+		"fix(api): Close rows after query",
+		"feat(cli): Add --json flag to status",
+		"docs: Describe the install steps",
+		"Update the readme badges",
+	})
+
+	if p.Conventional != 4 || p.Capitalized != 5 {
+		t.Errorf("AnalyzeStyle() conventional = %d, capitalized = %d", p.Conventional, p.Capitalized)
+	}
+	if p.Types["feat"] != 2 || p.Scopes["api"] != 2 || p.Scopes["cli"] != 1 {
+		t.Errorf("AnalyzeStyle() types = %v, scopes = %v", p.Types, p.Scopes)
+	}
+	if p.MedianLength != 32 || p.LongLength != 36 {
+		t.Errorf("AnalyzeStyle() lengths = %d, %d", p.MedianLength, p.LongLength)
+	}
+
+	describe := p.Describe()
+	for _, want := range []string{"4 of 5 are conventional commits", "types are feat, docs, fix", "capital letter", "scopes in use: api, cli"} {
+		if !strings.Contains(describe, want) {
+			t.Errorf("Describe() = %q, want it to contain %q", describe, want)
+		}
+	}
+
+	if got := src.AnalyzeStyle(nil).Describe(); got != "" {
+		t.Errorf("Describe() without samples = %q", got)
+	}
+}
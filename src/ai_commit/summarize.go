@@ -0,0 +1,167 @@
+package src
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DiffSummary is a diff reduced to fit a token budget
+type DiffSummary struct {
+	Text           string
+	Files          int
+	OmittedHunks   int
+	OmittedFiles   []string // files whose hunk bodies were all left out
+	OmittedTokens  int
+	BudgetExceeded bool     // even the per-file outline did not fit
+	Noise          []string // one line per lockfile, generated or binary file left out, see NoiseRules
+}
+
+// EstimateTokens gives a rough token count, about four bytes per token
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// Omissions describes what was left out, or "" if nothing was
+func (s DiffSummary) Omissions() string {
+	if s.OmittedHunks == 0 && !s.BudgetExceeded {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Omitted %d hunks (~%d tokens) to fit the context budget", s.OmittedHunks, s.OmittedTokens)
+	if len(s.OmittedFiles) > 0 {
+		fmt.Fprintf(&b, "; only headers shown for: %s", strings.Join(s.OmittedFiles, ", "))
+	}
+	if s.BudgetExceeded {
+		b.WriteString("; the file outline itself was cut short")
+	}
+	return b.String()
+}
+
+type rankedHunk struct {
+	file  int
+	hunk  int
+	score float64
+	cost  int
+}
+
+// SummarizeDiff reduces a unified diff to roughly budget tokens. Every file
+// keeps a one line description and its hunk headers (with the function
+// context git adds), renames and mode changes are collapsed to one line,
+// and hunk bodies are added in order of significance until the budget is
+// spent.
+func SummarizeDiff(diff string, budget int) DiffSummary {
+	files := ParseDiff(diff)
+	summary := DiffSummary{Files: len(files)}
+	if len(files) == 0 {
+		return summary
+	}
+
+	// The outline every file keeps as long as it fits, files past the
+	// budget are cut with all their hunks
+	shownFiles := len(files)
+	used := 0
+	for i, f := range files {
+		var b strings.Builder
+		b.WriteString(f.Describe() + "\n")
+		for _, h := range f.Hunks {
+			b.WriteString(h.Header + "\n")
+		}
+		cost := EstimateTokens(b.String())
+		if used+cost > budget && i > 0 {
+			shownFiles = i
+			used += EstimateTokens(fmt.Sprintf("... %d more files\n", len(files)-i))
+			break
+		}
+		used += cost
+	}
+
+	var ranked []rankedHunk
+	for i, f := range files {
+		weight := fileWeight(f.Path())
+		for j, h := range f.Hunks {
+			changed := h.Added + h.Removed
+			if changed == 0 {
+				continue
+			}
+			if i >= shownFiles {
+				summary.OmittedHunks++
+				summary.OmittedTokens += EstimateTokens(strings.Join(h.Lines, "\n"))
+				continue
+			}
+			score := float64(changed) * weight
+			// Hunks git could attach to a function are usually real code
+			if hunkContext(h.Header) != "" {
+				score *= 1.2
+			}
+			// Favour small hunks so more of the change fits
+			cost := EstimateTokens(strings.Join(h.Lines, "\n"))
+			score /= 1 + float64(cost)/200
+			ranked = append(ranked, rankedHunk{file: i, hunk: j, score: score, cost: cost})
+		}
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return ranked[a].score > ranked[b].score
+	})
+
+	included := make(map[[2]int]bool)
+	for _, r := range ranked {
+		if used+r.cost <= budget {
+			included[[2]int{r.file, r.hunk}] = true
+			used += r.cost
+		} else {
+			summary.OmittedHunks++
+			summary.OmittedTokens += r.cost
+		}
+	}
+
+	var b strings.Builder
+	for i, f := range files {
+		if i == shownFiles {
+			summary.BudgetExceeded = true
+			fmt.Fprintf(&b, "... %d more files\n", len(files)-i)
+			break
+		}
+
+		b.WriteString(f.Describe() + "\n")
+		shown := 0
+		for j, h := range f.Hunks {
+			b.WriteString(h.Header + "\n")
+			if included[[2]int{i, j}] {
+				b.WriteString(strings.Join(h.Lines, "\n") + "\n")
+				shown++
+			}
+		}
+		if shown == 0 && len(f.Hunks) > 0 {
+			summary.OmittedFiles = append(summary.OmittedFiles, f.Path())
+		}
+		b.WriteString("\n")
+	}
+
+	summary.Text = b.String()
+	return summary
+}
+
+// hunkContext returns the function context git appends to a hunk header
+func hunkContext(header string) string {
+	if i := strings.LastIndex(header, "@@"); i >= 0 && i+2 < len(header) {
+		return strings.TrimSpace(header[i+2:])
+	}
+	return ""
+}
+
+// fileWeight ranks how interesting changes to a file usually are
+func fileWeight(path string) float64 {
+	base := filepath.Base(path)
+	switch {
+	case strings.HasSuffix(base, "_test.go") || strings.Contains(path, "test"):
+		return 0.6
+	case strings.HasSuffix(base, ".md") || strings.HasSuffix(base, ".txt"):
+		return 0.5
+	case strings.HasSuffix(base, ".json") || strings.HasSuffix(base, ".yml") || strings.HasSuffix(base, ".yaml") || strings.HasSuffix(base, ".toml"):
+		return 0.7
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,281 @@
+package src
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// DefaultTemplate is the prompt template used when none is selected
+const DefaultTemplate = "conventional"
+
+// TemplateFormats are the message formats a template can ask for. The
+// format decides how the answer is validated, see Validate.
+var TemplateFormats = []string{"conventional", "gitmoji", "plain"}
+
+// BuiltinTemplates are the prompt templates shipped with ai_commit. A
+// template file with the same name in the repository or the user config
+// directory takes precedence.
+var BuiltinTemplates = map[string]string{
+	"conventional": conventionalTemplate,
+	"gitmoji":      gitmojiTemplate,
+	"plain":        plainTemplate,
+}
+
+const conventionalTemplate = `You are an AI Git assistant. Your task is to write a conventional commit message in the format:
+<type>(<scope>): <subject>
+
+I've analyzed the changes and suggest:
+- Type: {{.Type}} (but choose the most appropriate from: {{join .Rules.Types ", "}})
+- Scope: {{.Scope}} (update if you think another scope is more appropriate)
+
+Be concise but descriptive. The subject should:
+- Use imperative, present tense (e.g., "change" not "changed" or "changes")
+- Not capitalize the first letter
+- No period at the end
+- Keep the whole first line under {{.Rules.HeaderMaxLength}} characters
+
+If exported Go API changes are listed, add a blank line after the subject
+and a body with one "- " bullet per notable change, wrapped at 72 characters.
+{{- with .Style}}
+
+Recent commits in this repository, match their style:
+{{- range .Examples}}
+- {{.}}
+{{- end}}
+
+{{.Describe}}
+{{- end}}
+{{.FooterInstructions}}
+ONLY return the commit message, nothing else.
+
+Repository changes summary:
+{{.Summary}}`
+
+const gitmojiTemplate = `{{define "format"}}gitmoji{{end}}You are an AI Git assistant. Your task is to write a gitmoji commit message in the format:
+<emoji> <subject>
+
+Pick the emoji that fits the change best, for example:
+✨ new feature, 🐛 bug fix, 📝 documentation, ♻️ refactor, ✅ tests,
+⚡️ performance, 🎨 code style, 🔥 removed code or files, 🔧 configuration,
+📦️ build and dependencies, 👷 CI, 🚑️ critical hotfix
+
+I've analyzed the changes and suggest {{gitmoji .Type}} for them.
+
+The subject should:
+- Use imperative, present tense (e.g., "change" not "changed" or "changes")
+- No period at the end
+- Keep the whole first line under {{.Rules.HeaderMaxLength}} characters
+{{- with .RecentCommits}}
+
+Recent commits in this repository, match their style:
+{{- range .}}
+- {{.}}
+{{- end}}
+{{- end}}
+{{- with .Style}}
+
+{{.Describe}}
+{{- end}}
+{{.FooterInstructions}}
+ONLY return the commit message, nothing else.
+
+Repository changes summary:
+{{.Summary}}`
+
+const plainTemplate = `{{define "format"}}plain{{end}}You are an AI Git assistant. Your task is to write a plain commit message:
+a short subject line, optionally followed by a blank line and a body.
+
+The subject should:
+- Start with a capital letter and use the imperative mood (e.g., "Change" not "Changed" or "Changes")
+- No period at the end
+- Keep it under {{.Rules.HeaderMaxLength}} characters
+- Not use a "type:" or "type(scope):" prefix
+
+Only add a body, wrapped at 72 characters, when the subject cannot say why
+the change was made.
+{{- with .RecentCommits}}
+
+Recent commits in this repository, match their style:
+{{- range .}}
+- {{.}}
+{{- end}}
+{{- end}}
+{{- with .Style}}
+
+{{.Describe}}
+{{- end}}
+{{.FooterInstructions}}
+ONLY return the commit message, nothing else.
+
+Repository changes summary:
+{{.Summary}}`
+
+// PromptData is what a prompt template is rendered with
+type PromptData struct {
+	Branch        string
+	Files         []string      // files that will be part of the commit
+	Summary       string        // files, diffs and Go API changes, summarized to the context budget
+	Type          string        // suggested type, see DetectType
+	Scope         string        // suggested scope, see DetectScope
+	RecentCommits []string      // subjects of the latest commits, newest first
+	Style         *StyleProfile // learned from the history, nil unless enabled
+	Conventions   Conventions
+	Rules         Rules
+	Footers       []Footer // detected breaking change and issue footers
+}
+
+// FooterInstructions describes the required footers of the conventions and
+// the detected ones, or "" when there are none
+func (d PromptData) FooterInstructions() string {
+	return footerInstructions(d.Rules.RequiredFooters) + footerContext(d.Footers)
+}
+
+// PromptTemplate is a parsed prompt template. A template declares a message
+// format other than conventional with {{define "format"}}plain{{end}}.
+type PromptTemplate struct {
+	Name   string
+	Source string // file the template was read from, "" for built-ins
+	Format string
+	text   string
+	tmpl   *template.Template
+}
+
+var gitmojis = map[string]string{
+	"feat": "✨", "fix": "🐛", "docs": "📝", "style": "🎨", "refactor": "♻️",
+	"perf": "⚡️", "test": "✅", "build": "📦️", "ci": "👷", "chore": "🔧",
+	"revert": "⏪️",
+}
+
+var templateFuncs = template.FuncMap{
+	"join":    strings.Join,
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"trim":    strings.TrimSpace,
+	"gitmoji": gitmojiFor,
+}
+
+// gitmojiFor maps a conventional commit type to its emoji
+func gitmojiFor(commitType string) string {
+	if emoji, ok := gitmojis[commitType]; ok {
+		return emoji
+	}
+	return "🔧"
+}
+
+// TemplateDirs returns where template files are looked up, in order: the
+// .zvezda/templates directory of the repository, then the templates
+// directory next to the user config file
+func TemplateDirs(root string) []string {
+	dirs := []string{filepath.Join(root, ".zvezda", "templates")}
+	if config, err := ConfigPath(); err == nil {
+		dirs = append(dirs, filepath.Join(filepath.Dir(config), "templates"))
+	}
+	return dirs
+}
+
+// LoadPromptTemplate finds the template called name, as <name>.tmpl in one
+// of the TemplateDirs or among the BuiltinTemplates
+func LoadPromptTemplate(root, name string) (*PromptTemplate, error) {
+	if name == "" {
+		name = DefaultTemplate
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid template name %q", name)
+	}
+
+	for _, dir := range TemplateDirs(root) {
+		path := filepath.Join(dir, name+".tmpl")
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return parsePromptTemplate(name, path, string(data))
+	}
+
+	if text, ok := BuiltinTemplates[name]; ok {
+		return parsePromptTemplate(name, "", text)
+	}
+	return nil, fmt.Errorf("unknown template %q, built-in templates are conventional, gitmoji and plain", name)
+}
+
+func parsePromptTemplate(name, source, text string) (*PromptTemplate, error) {
+	where := source
+	if where == "" {
+		where = name
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", where, err)
+	}
+
+	t := &PromptTemplate{Name: name, Source: source, Format: "conventional", text: text, tmpl: tmpl}
+	if format := tmpl.Lookup("format"); format != nil {
+		var b bytes.Buffer
+		if err := format.Execute(&b, nil); err != nil {
+			return nil, fmt.Errorf("template %s: %w", where, err)
+		}
+		t.Format = strings.TrimSpace(b.String())
+		if !contains(TemplateFormats, t.Format) {
+			return nil, fmt.Errorf("template %s: unknown format %q, use one of: %s", where, t.Format, strings.Join(TemplateFormats, ", "))
+		}
+	}
+	return t, nil
+}
+
+// Render executes the template
+func (t *PromptTemplate) Render(data PromptData) (string, error) {
+	var b bytes.Buffer
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("rendering template %s: %w", t.Name, err)
+	}
+	return b.String(), nil
+}
+
+// TemplateName returns the template the repository uses: Options.Template,
+// then the template of the conventions, then the one of the user config
+func (r *Repo) TemplateName(opts Options) string {
+	if opts.Template != "" {
+		return opts.Template
+	}
+	if conventions, err := LoadConventions(r.Path); err == nil && conventions.Template != "" {
+		return conventions.Template
+	}
+	if config, err := LoadConfig(); err == nil && config.Template != "" {
+		return config.Template
+	}
+	return DefaultTemplate
+}
+
+// PromptTemplate loads the template the repository uses
+func (r *Repo) PromptTemplate(opts Options) (*PromptTemplate, error) {
+	return LoadPromptTemplate(r.Path, r.TemplateName(opts))
+}
+
+// Rules returns the validation rules of the conventions, for the message
+// format of the prompt template
+func (r *Repo) Rules(opts Options) (Rules, error) {
+	conventions, err := LoadConventions(r.Path)
+	if err != nil {
+		return Rules{}, err
+	}
+	t, err := r.PromptTemplate(opts)
+	if err != nil {
+		return Rules{}, err
+	}
+	rules := conventions.Rules()
+	rules.Format = t.Format
+	if style := r.Style(opts); style != nil && style.Mostly(style.Capitalized) {
+		rules.AllowCapitalized = true
+	}
+	return rules, nil
+}
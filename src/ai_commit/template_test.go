@@ -0,0 +1,67 @@
+package src_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+func TestLoadPromptTemplate(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	root := t.TempDir()
+	dir := filepath.Join(root, ".zvezda", "templates")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"team.tmpl":    `{{define "format"}}plain{{end}}Branch {{.Branch}}, files: {{join .Files ", "}}`,
+		"broken.tmpl":  `{{.Branch`,
+		"unknown.tmpl": `{{define "format"}}emoji{{end}}hi`,
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		wantErr bool
+	}{
+		{name: "", format: "conventional"},
+		{name: "gitmoji", format: "gitmoji"},
+		{name: "plain", format: "plain"},
+		{name: "team", format: "plain"},
+		{name: "broken", wantErr: true},
+		{name: "unknown", wantErr: true},
+		{name: "missing", wantErr: true},
+		{name: "../team", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := src.LoadPromptTemplate(root, tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadPromptTemplate() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && tmpl.Format != tt.format {
+				t.Errorf("LoadPromptTemplate() format = %q, want %q", tmpl.Format, tt.format)
+			}
+		})
+	}
+
+	tmpl, _ := src.LoadPromptTemplate(root, "team")
+	got, err := tmpl.Render(src.PromptData{Branch: "main", Files: []string{"a.go", "b.go"}})
+	if err != nil || got != "Branch main, files: a.go, b.go" {
+		t.Errorf("Render() = %q, %v", got, err)
+	}
+
+	tmpl, _ = src.LoadPromptTemplate(root, "conventional")
+	got, _ = tmpl.Render(src.PromptData{Type: "feat", Summary: "SUMMARY", Rules: src.DefaultRules()})
+	if !strings.Contains(got, "Type: feat") || !strings.HasSuffix(got, "SUMMARY") {
+		t.Errorf("Render() of the built-in template = %q", got)
+	}
+}
@@ -0,0 +1,205 @@
+package src
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Rules controls what Validate accepts
+type Rules struct {
+	Types           []string
+	HeaderMaxLength int
+	BodyWrap        int
+	BodyMaxLines    int
+	RequiredFooters []FooterRule
+
+	// Format is the message format, one of TemplateFormats. Type and scope
+	// rules only apply to conventional messages, the default.
+	Format string
+
+	// AllowCapitalized accepts conventional subjects starting with a
+	// capital letter, for repositories whose history is written that way
+	AllowCapitalized bool
+}
+
+// DefaultRules returns the conventional commit rules used by ai_commit
+func DefaultRules() Rules {
+	return Rules{
+		Types:           DefaultTypes,
+		HeaderMaxLength: 72,
+		BodyWrap:        72,
+		BodyMaxLines:    15,
+	}
+}
+
+// Common verbs in their non-imperative forms, mapped to the imperative
+var nonImperative = map[string]string{}
+
+func init() {
+	verbs := []string{
+		"add", "allow", "bump", "change", "clean", "create", "delete", "document",
+		"drop", "enable", "disable", "ensure", "extract", "fix", "handle", "implement",
+		"improve", "introduce", "make", "merge", "move", "optimize", "refactor",
+		"remove", "rename", "replace", "revert", "simplify", "support", "update",
+		"upgrade", "use", "validate",
+	}
+	for _, v := range verbs {
+		nonImperative[thirdPerson(v)] = v
+		nonImperative[pastTense(v)] = v
+		nonImperative[gerund(v)] = v
+	}
+}
+
+func thirdPerson(v string) string {
+	if strings.HasSuffix(v, "x") || strings.HasSuffix(v, "sh") || strings.HasSuffix(v, "ch") {
+		return v + "es"
+	}
+	return v + "s"
+}
+
+func pastTense(v string) string {
+	switch {
+	case v == "make":
+		return "made"
+	case v == "drop":
+		return "dropped"
+	case strings.HasSuffix(v, "e"):
+		return v + "d"
+	default:
+		return v + "ed"
+	}
+}
+
+func gerund(v string) string {
+	switch {
+	case v == "use" || strings.HasSuffix(v, "e") && !strings.HasSuffix(v, "ee"):
+		return v[:len(v)-1] + "ing"
+	case v == "drop":
+		return "dropping"
+	default:
+		return v + "ing"
+	}
+}
+
+var gitmojiHeaderRegex = regexp.MustCompile(`^(:[a-z0-9_+-]+:|[^\x00-\x7F]+)\s+(.*)$`)
+
+// parseMessage parses a message of the given format. The type of a gitmoji
+// message is its emoji, plain messages have none.
+func parseMessage(message, format string) (ConventionalCommit, error) {
+	switch format {
+	case "gitmoji":
+		lines := strings.Split(strings.TrimSpace(message), "\n")
+		matches := gitmojiHeaderRegex.FindStringSubmatch(strings.TrimSpace(lines[0]))
+		if matches == nil {
+			return ConventionalCommit{}, fmt.Errorf("header %q is not <emoji> <subject>", lines[0])
+		}
+		commit := ConventionalCommit{Type: matches[1], Subject: matches[2]}
+		commit.Body, commit.Footers = parseBody(lines[1:])
+		return commit, nil
+	case "plain":
+		lines := strings.Split(strings.TrimSpace(message), "\n")
+		commit := ConventionalCommit{Subject: strings.TrimSpace(lines[0])}
+		commit.Body, commit.Footers = parseBody(lines[1:])
+		return commit, nil
+	default:
+		return ParseConventional(message)
+	}
+}
+
+// Validate checks a commit message against the rules and returns a list of
+// human readable violations, empty when the message is acceptable
+func Validate(message string, rules Rules) []string {
+	var violations []string
+
+	commit, err := parseMessage(message, rules.Format)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	conventional := rules.Format == "" || rules.Format == "conventional"
+	if conventional && len(rules.Types) > 0 && !contains(rules.Types, commit.Type) {
+		violations = append(violations, fmt.Sprintf("type %q is not one of: %s", commit.Type, strings.Join(rules.Types, ", ")))
+	}
+	if strings.ContainsAny(commit.Scope, " \t") {
+		violations = append(violations, fmt.Sprintf("scope %q must not contain spaces", commit.Scope))
+	}
+	if rules.Format == "plain" && headerRegex.MatchString(commit.Subject) {
+		violations = append(violations, "plain messages must not start with a type prefix")
+	}
+
+	subject := commit.Subject
+	header := strings.SplitN(strings.TrimSpace(message), "\n", 2)[0]
+	if strings.TrimSpace(subject) == "" {
+		violations = append(violations, "subject is empty")
+	} else {
+		if rules.HeaderMaxLength > 0 && len(header) > rules.HeaderMaxLength {
+			violations = append(violations, fmt.Sprintf("header is %d characters, keep the whole first line under %d", len(header), rules.HeaderMaxLength))
+		}
+		if strings.HasSuffix(subject, ".") {
+			violations = append(violations, "subject must not end with a period")
+		}
+		first := []rune(subject)[0]
+		if conventional && !rules.AllowCapitalized && unicode.IsUpper(first) && !isAcronym(strings.Fields(subject)[0]) {
+			violations = append(violations, "subject must not start with a capital letter")
+		}
+		word := strings.ToLower(strings.Fields(subject)[0])
+		if imperative, ok := nonImperative[word]; ok {
+			violations = append(violations, fmt.Sprintf("subject must use the imperative mood (%q instead of %q)", imperative, word))
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		violations = append(violations, "the header must be followed by a blank line")
+	}
+
+	if commit.Body != "" {
+		bodyLines := strings.Split(commit.Body, "\n")
+		if rules.BodyMaxLines > 0 && len(bodyLines) > rules.BodyMaxLines {
+			violations = append(violations, fmt.Sprintf("body is %d lines, keep it under %d", len(bodyLines), rules.BodyMaxLines))
+		}
+		if rules.BodyWrap > 0 {
+			for _, line := range bodyLines {
+				if len(line) > rules.BodyWrap && !strings.Contains(line, "://") {
+					violations = append(violations, fmt.Sprintf("body lines must be wrapped at %d characters", rules.BodyWrap))
+					break
+				}
+			}
+		}
+	}
+
+	for _, required := range rules.RequiredFooters {
+		found := false
+		for _, f := range commit.Footers {
+			if strings.EqualFold(f.Token, required.Token) {
+				found = true
+				if required.Pattern != "" {
+					if matched, _ := regexp.MatchString(required.Pattern, f.Value); !matched {
+						violations = append(violations, fmt.Sprintf("footer %q value %q does not match %s", f.Token, f.Value, required.Pattern))
+					}
+				}
+				break
+			}
+		}
+		if !found {
+			violations = append(violations, fmt.Sprintf("missing required %q footer", required.Token))
+		}
+	}
+
+	return violations
+}
+
+func isAcronym(word string) bool {
+	return len(word) > 1 && strings.ToUpper(word) == word
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,372 @@
+package src
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Module is a package or project declared in a workspace manifest
+type Module struct {
+	Name      string
+	Dir       string // relative to the repository root, slash separated
+	Ecosystem string // "npm", "cargo", "python", "go", "maven", "gradle"
+}
+
+// DiscoverModules reads the workspace manifests at the repository root:
+// package.json workspaces, Cargo.toml members, pyproject.toml packages,
+// go.work modules and Maven/Gradle subprojects
+func DiscoverModules(root string) []Module {
+	var modules []Module
+	modules = append(modules, npmModules(root)...)
+	modules = append(modules, cargoModules(root)...)
+	modules = append(modules, pythonModules(root)...)
+	modules = append(modules, goWorkModules(root)...)
+	modules = append(modules, mavenModules(root, "", make(map[string]bool))...)
+	modules = append(modules, gradleModules(root)...)
+
+	// Longest directories first so nested modules win in OwningModule
+	sort.SliceStable(modules, func(i, j int) bool {
+		return len(modules[i].Dir) > len(modules[j].Dir)
+	})
+	return modules
+}
+
+// OwningModule returns the module whose directory contains the file. A
+// module at the repository root, such as "use ." in go.work, owns nothing:
+// it would contain every file.
+func OwningModule(modules []Module, file string) (Module, bool) {
+	file = filepath.ToSlash(file)
+	for _, m := range modules {
+		if m.Dir == "" || m.Dir == "." {
+			continue
+		}
+		if file == m.Dir || strings.HasPrefix(file, m.Dir+"/") {
+			return m, true
+		}
+	}
+	return Module{}, false
+}
+
+// ModuleScopes maps each file to the name of its owning module and returns
+// the distinct names. Files outside any module are ignored.
+func (r *Repo) ModuleScopes(files []string) []string {
+	modules := DiscoverModules(r.Path)
+	if len(modules) == 0 {
+		return nil
+	}
+
+	names := make(map[string]bool)
+	for _, file := range files {
+		if m, ok := OwningModule(modules, file); ok {
+			names[m.Name] = true
+		}
+	}
+
+	var result []string
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// expandDirs resolves workspace member patterns such as "packages/*"
+// into directories containing the given manifest
+func expandDirs(root string, patterns []string, manifest string) []string {
+	var dirs []string
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "./")
+		if pattern == "" || strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			if _, err := os.Stat(filepath.Join(match, manifest)); err != nil {
+				continue
+			}
+			if rel, err := filepath.Rel(root, match); err == nil {
+				dirs = append(dirs, filepath.ToSlash(rel))
+			}
+		}
+	}
+	return dirs
+}
+
+// shortName turns "@org/ui" or "github.com/org/repo/api" into a scope
+func shortName(name string) string {
+	return path.Base(strings.TrimSpace(name))
+}
+
+func npmModules(root string) []Module {
+	data, err := os.ReadFile(filepath.Join(root, "package.json"))
+	if err != nil {
+		return nil
+	}
+
+	var manifest struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+	if json.Unmarshal(data, &manifest) != nil || len(manifest.Workspaces) == 0 {
+		return nil
+	}
+
+	// Either ["packages/*"] or {"packages": ["packages/*"]}
+	var patterns []string
+	if json.Unmarshal(manifest.Workspaces, &patterns) != nil {
+		var object struct {
+			Packages []string `json:"packages"`
+		}
+		if json.Unmarshal(manifest.Workspaces, &object) != nil {
+			return nil
+		}
+		patterns = object.Packages
+	}
+
+	var modules []Module
+	for _, dir := range expandDirs(root, patterns, "package.json") {
+		name := path.Base(dir)
+		if data, err := os.ReadFile(filepath.Join(root, dir, "package.json")); err == nil {
+			var pkg struct {
+				Name string `json:"name"`
+			}
+			if json.Unmarshal(data, &pkg) == nil && pkg.Name != "" {
+				name = shortName(pkg.Name)
+			}
+		}
+		modules = append(modules, Module{Name: name, Dir: dir, Ecosystem: "npm"})
+	}
+	return modules
+}
+
+var (
+	tomlSectionRegex = regexp.MustCompile(`^\s*\[([^\]]+)\]\s*$`)
+	tomlStringRegex  = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+)
+
+// tomlValue returns the raw value of key inside section, following
+// multi-line arrays. It only understands the subset of TOML used by
+// Cargo.toml and pyproject.toml manifests.
+func tomlValue(content, section, key string) string {
+	current := ""
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if m := tomlSectionRegex.FindStringSubmatch(line); m != nil {
+			current = strings.TrimSpace(m[1])
+			continue
+		}
+		if current != section {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(name) != key {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, "[") {
+			depth := strings.Count(value, "[") - strings.Count(value, "]")
+			for depth > 0 && i+1 < len(lines) {
+				i++
+				value += "\n" + lines[i]
+				depth += strings.Count(lines[i], "[") - strings.Count(lines[i], "]")
+			}
+		}
+		return value
+	}
+	return ""
+}
+
+func tomlStrings(value string) []string {
+	var result []string
+	for _, m := range tomlStringRegex.FindAllStringSubmatch(value, -1) {
+		result = append(result, m[1]+m[2])
+	}
+	return result
+}
+
+func tomlName(root, dir, file, section string) string {
+	data, err := os.ReadFile(filepath.Join(root, dir, file))
+	if err != nil {
+		return ""
+	}
+	if names := tomlStrings(tomlValue(string(data), section, "name")); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+func cargoModules(root string) []Module {
+	data, err := os.ReadFile(filepath.Join(root, "Cargo.toml"))
+	if err != nil {
+		return nil
+	}
+
+	members := tomlStrings(tomlValue(string(data), "workspace", "members"))
+	var modules []Module
+	for _, dir := range expandDirs(root, members, "Cargo.toml") {
+		name := tomlName(root, dir, "Cargo.toml", "package")
+		if name == "" {
+			name = path.Base(dir)
+		}
+		modules = append(modules, Module{Name: name, Dir: dir, Ecosystem: "cargo"})
+	}
+	return modules
+}
+
+var poetryIncludeRegex = regexp.MustCompile(`include\s*=\s*"([^"]+)"(?:\s*,\s*from\s*=\s*"([^"]+)")?`)
+
+func pythonModules(root string) []Module {
+	data, err := os.ReadFile(filepath.Join(root, "pyproject.toml"))
+	if err != nil {
+		return nil
+	}
+	content := string(data)
+	var modules []Module
+
+	// uv workspaces
+	members := tomlStrings(tomlValue(content, "tool.uv.workspace", "members"))
+	for _, dir := range expandDirs(root, members, "pyproject.toml") {
+		name := tomlName(root, dir, "pyproject.toml", "project")
+		if name == "" {
+			name = path.Base(dir)
+		}
+		modules = append(modules, Module{Name: name, Dir: dir, Ecosystem: "python"})
+	}
+
+	// setuptools packages, laid out flat or under src/
+	for _, pkg := range tomlStrings(tomlValue(content, "tool.setuptools", "packages")) {
+		dir := strings.ReplaceAll(pkg, ".", "/")
+		if _, err := os.Stat(filepath.Join(root, "src", dir)); err == nil {
+			dir = "src/" + dir
+		}
+		modules = append(modules, Module{Name: path.Base(dir), Dir: dir, Ecosystem: "python"})
+	}
+
+	// poetry packages = [{ include = "foo", from = "src" }]
+	for _, m := range poetryIncludeRegex.FindAllStringSubmatch(tomlValue(content, "tool.poetry", "packages"), -1) {
+		dir := m[1]
+		if m[2] != "" {
+			dir = m[2] + "/" + m[1]
+		}
+		modules = append(modules, Module{Name: m[1], Dir: dir, Ecosystem: "python"})
+	}
+
+	return modules
+}
+
+func goWorkModules(root string) []Module {
+	data, err := os.ReadFile(filepath.Join(root, "go.work"))
+	if err != nil {
+		return nil
+	}
+
+	// use ./a or use ( ./a ./b )
+	var dirs []string
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(strings.Split(line, "//")[0])
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+		case inBlock && line != "":
+			dirs = append(dirs, line)
+		case line == "use (":
+			inBlock = true
+		case strings.HasPrefix(line, "use "):
+			dirs = append(dirs, strings.TrimSpace(strings.TrimPrefix(line, "use ")))
+		}
+	}
+
+	var modules []Module
+	for _, dir := range dirs {
+		dir = path.Clean(strings.Trim(filepath.ToSlash(dir), `"`))
+		name := path.Base(dir)
+		if data, err := os.ReadFile(filepath.Join(root, dir, "go.mod")); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if strings.HasPrefix(line, "module ") {
+					name = shortName(strings.TrimPrefix(line, "module "))
+					break
+				}
+			}
+		}
+		modules = append(modules, Module{Name: name, Dir: dir, Ecosystem: "go"})
+	}
+	return modules
+}
+
+// mavenModules lists the modules of the pom in dir and of theirs. visited
+// holds the absolute directories already read, a module listing its parent
+// or two poms listing each other are read once.
+func mavenModules(root, dir string, visited map[string]bool) []Module {
+	if abs, err := filepath.Abs(filepath.Join(root, dir)); err == nil {
+		visited[abs] = true
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, dir, "pom.xml"))
+	if err != nil {
+		return nil
+	}
+
+	var pom struct {
+		Modules []string `xml:"modules>module"`
+	}
+	if xml.Unmarshal(data, &pom) != nil {
+		return nil
+	}
+
+	var modules []Module
+	for _, sub := range pom.Modules {
+		subDir := path.Join(dir, strings.TrimSpace(sub))
+		if abs, err := filepath.Abs(filepath.Join(root, subDir)); err != nil || visited[abs] {
+			continue
+		}
+		name := path.Base(subDir)
+		if data, err := os.ReadFile(filepath.Join(root, subDir, "pom.xml")); err == nil {
+			var child struct {
+				ArtifactID string `xml:"artifactId"`
+			}
+			if xml.Unmarshal(data, &child) == nil && child.ArtifactID != "" {
+				name = child.ArtifactID
+			}
+		}
+		modules = append(modules, Module{Name: name, Dir: subDir, Ecosystem: "maven"})
+		modules = append(modules, mavenModules(root, subDir, visited)...)
+	}
+	return modules
+}
+
+var gradleIncludeRegex = regexp.MustCompile(`["']:?([A-Za-z0-9_.\-:]+)["']`)
+
+func gradleModules(root string) []Module {
+	var content string
+	for _, name := range []string{"settings.gradle", "settings.gradle.kts"} {
+		if data, err := os.ReadFile(filepath.Join(root, name)); err == nil {
+			content = string(data)
+			break
+		}
+	}
+
+	var modules []Module
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "include") || strings.HasPrefix(line, "includeBuild") {
+			continue
+		}
+		// include ':app', ':lib:core' or include("app")
+		for _, m := range gradleIncludeRegex.FindAllStringSubmatch(line, -1) {
+			project := m[1]
+			dir := strings.ReplaceAll(project, ":", "/")
+			modules = append(modules, Module{Name: path.Base(dir), Dir: dir, Ecosystem: "gradle"})
+		}
+	}
+	return modules
+}
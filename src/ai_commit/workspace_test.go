@@ -0,0 +1,144 @@
+package src_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/NoamFav/Zvezda/src/ai_commit"
+)
+
+// writeFiles creates the files, keyed by slash separated path, under a
+// temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestDiscoverModules(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string // "ecosystem name dir"
+	}{
+		{
+			name: "npm",
+			files: map[string]string{
+				"package.json":              `{"workspaces": {"packages": ["packages/*"]}}`,
+				"packages/ui/package.json":  `{"name": "@acme/ui"}`,
+				"packages/api/package.json": `{}`,
+				"packages/docs/README.md":   "no manifest",
+			},
+			want: []string{"npm api packages/api", "npm ui packages/ui"},
+		},
+		{
+			name: "cargo toml",
+			files: map[string]string{
+				"Cargo.toml":             "[workspace]\nmembers = [\n  \"crates/*\",\n]\n",
+				"crates/core/Cargo.toml": "[package]\nname = \"acme-core\"\nversion = \"0.1.0\"\n",
+				"crates/cli/Cargo.toml":  "[package]\nversion = \"0.1.0\"\n",
+			},
+			want: []string{"cargo cli crates/cli", "cargo acme-core crates/core"},
+		},
+		{
+			name: "pyproject toml",
+			files: map[string]string{
+				"pyproject.toml":             "[tool.uv.workspace]\nmembers = ['libs/*']\n\n[tool.poetry]\npackages = [{ include = \"app\", from = \"src\" }]\n",
+				"libs/models/pyproject.toml": "[project]\nname = \"models\"\n",
+			},
+			want: []string{"python models libs/models", "python app src/app"},
+		},
+		{
+			name: "maven xml",
+			files: map[string]string{
+				"pom.xml":            "<project><modules><module>server</module></modules></project>",
+				"server/pom.xml":     "<project><artifactId>acme-server</artifactId><modules><module>web</module></modules></project>",
+				"server/web/pom.xml": "<project><artifactId>acme-web</artifactId></project>",
+			},
+			want: []string{"maven acme-server server", "maven acme-web server/web"},
+		},
+		{
+			name: "maven cycles",
+			files: map[string]string{
+				"pom.xml":   "<project><modules><module>a</module><module>b</module></modules></project>",
+				"a/pom.xml": "<project><artifactId>a</artifactId><modules><module>..</module><module>../b</module></modules></project>",
+				"b/pom.xml": "<project><artifactId>b</artifactId><modules><module>../a/</module></modules></project>",
+			},
+			want: []string{"maven a a", "maven b b"},
+		},
+		{
+			name: "go.work",
+			files: map[string]string{
+				"go.work":      "go 1.22\n\nuse (\n\t.\n\t./tools // generators\n)\n",
+				"go.mod":       "module example.com/acme\n",
+				"tools/go.mod": "module example.com/acme/tools\n",
+			},
+			want: []string{"go acme .", "go tools tools"},
+		},
+		{
+			name: "gradle",
+			files: map[string]string{
+				"settings.gradle.kts": "rootProject.name = \"acme\"\ninclude(\":app\", \":lib:core\")\nincludeBuild(\"build-logic\")\n",
+			},
+			want: []string{"gradle app app", "gradle core lib/core"},
+		},
+		{
+			name:  "no manifests",
+			files: map[string]string{"main.go": "package main\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range src.DiscoverModules(writeFiles(t, tt.files)) {
+				got = append(got, m.Ecosystem+" "+m.Name+" "+m.Dir)
+			}
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("DiscoverModules() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOwningModule(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"go.work":          "use (\n\t.\n\t./tools\n\t./tools/gen\n)\n",
+		"go.mod":           "module example.com/acme\n",
+		"tools/go.mod":     "module example.com/acme/tools\n",
+		"tools/gen/go.mod": "module example.com/acme/gen\n",
+	})
+	modules := src.DiscoverModules(root)
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"tools/lint.go", "tools"},
+		{"tools/gen/main.go", "gen"},
+		{"tools", "tools"},
+		{"toolsets/x.go", ""},
+		{"main.go", ""},
+		{"README.md", ""},
+	}
+	for _, tt := range tests {
+		m, ok := src.OwningModule(modules, tt.file)
+		if ok != (tt.want != "") || m.Name != tt.want {
+			t.Errorf("OwningModule(%q) = %q, %v, want %q", tt.file, m.Name, ok, tt.want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package repo_manager
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -10,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/NoamFav/Zvezda/src/ai_commit"
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbletea"
@@ -200,6 +203,8 @@ type Model struct {
 	startTime    time.Time
 	logs         []LogEntry
 	currentOps   []string // Current operations being performed
+	ctx          context.Context
+	cancel       context.CancelFunc
 }
 
 // Messages
@@ -230,6 +235,8 @@ func initialModel(config Config) Model {
 
 	p := progress.New(progress.WithScaledGradient("#f38ba8", "#a6e3a1"))
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return Model{
 		config:    config,
 		state:     "scanning",
@@ -237,6 +244,8 @@ func initialModel(config Config) Model {
 		progress:  p,
 		startTime: time.Now(),
 		logs:      []LogEntry{},
+		ctx:       ctx,
+		cancel:    cancel,
 	}
 }
 
@@ -267,6 +276,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c", "q":
+			// Stop any git or ai_commit process still running
+			m.cancel()
 			return m, tea.Quit
 		}
 
@@ -280,7 +291,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		// Start processing the first repository
-		return m, processNextRepository(m.repositories[0], m.config)
+		return m, processNextRepository(m.ctx, m.repositories[0], m.config)
 
 	case repoProcessedMsg:
 		// Add logs from processing
@@ -312,7 +323,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Process next repository
 		nextRepo := m.repositories[m.currentRepo]
-		return m, processNextRepository(nextRepo, m.config)
+		return m, processNextRepository(m.ctx, nextRepo, m.config)
 
 	case operationUpdateMsg:
 		if msg.success {
@@ -549,59 +560,122 @@ func boolToYesNo(b bool) string {
 // Commands
 func scanRepositories(config Config) tea.Cmd {
 	return func() tea.Msg {
-		entries, err := os.ReadDir(config.BaseDir)
+		repos, err := FindRepositories(config)
 		if err != nil {
 			log.Error("Failed to read directory", "error", err)
 			return scanCompleteMsg{repos: []Repository{}}
 		}
+		return scanCompleteMsg{repos: repos}
+	}
+}
 
-		var repos []Repository
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				continue
-			}
+// FindRepositories lists the git repositories directly under the base
+// directory, applying the exclude and only lists
+func FindRepositories(config Config) ([]Repository, error) {
+	entries, err := os.ReadDir(config.BaseDir)
+	if err != nil {
+		return nil, err
+	}
 
-			name := entry.Name()
+	var repos []Repository
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
 
-			// Check exclusions
-			if contains(config.ExcludeList, name) {
-				continue
-			}
+		name := entry.Name()
 
-			// Check only list
-			if len(config.OnlyList) > 0 && !contains(config.OnlyList, name) {
-				continue
-			}
+		// Check exclusions
+		if contains(config.ExcludeList, name) {
+			continue
+		}
 
-			repoPath := filepath.Join(config.BaseDir, name)
-			gitPath := filepath.Join(repoPath, ".git")
+		// Check only list
+		if len(config.OnlyList) > 0 && !contains(config.OnlyList, name) {
+			continue
+		}
 
-			if _, err := os.Stat(gitPath); err == nil {
-				// Get current branch
-				branch := getCurrentBranch(repoPath)
+		repoPath := filepath.Join(config.BaseDir, name)
+		gitPath := filepath.Join(repoPath, ".git")
 
-				repo := Repository{
-					Name:   name,
-					Path:   repoPath,
-					Branch: branch,
-					Status: "pending",
-				}
-				repos = append(repos, repo)
+		if _, err := os.Stat(gitPath); err == nil {
+			// Get current branch
+			branch := getCurrentBranch(repoPath)
+
+			repo := Repository{
+				Name:   name,
+				Path:   repoPath,
+				Branch: branch,
+				Status: "pending",
 			}
+			repos = append(repos, repo)
 		}
+	}
 
-		sort.Slice(repos, func(i, j int) bool {
-			return repos[i].Name < repos[j].Name
-		})
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Name < repos[j].Name
+	})
 
-		return scanCompleteMsg{repos: repos}
+	return repos, nil
+}
+
+// manageHooks installs or removes the prepare-commit-msg hook in every
+// repository and prints one line per repository
+func manageHooks(config Config, install bool) error {
+	repos, err := FindRepositories(config)
+	if err != nil {
+		return err
+	}
+
+	for _, repo := range repos {
+		gitRepo, err := src.OpenRepo(repo.Path)
+		if err == nil && install {
+			err = gitRepo.InstallHook()
+		} else if err == nil {
+			err = gitRepo.UninstallHook()
+		}
+		if err != nil {
+			fmt.Println(errorStyle.Render(IconError + " " + repo.Name + ": " + err.Error()))
+			continue
+		}
+		fmt.Println(successStyle.Render(IconSuccess + " " + repo.Name))
+	}
+	return nil
+}
+
+// releaseAll tags the next version of every repository with changes worth
+// releasing, and prints one line per repository
+func releaseAll(config Config, pre string, push bool) error {
+	repos, err := FindRepositories(config)
+	if err != nil {
+		return err
+	}
+
+	for _, repo := range repos {
+		gitRepo, err := src.OpenRepo(repo.Path)
+		if err != nil {
+			fmt.Println(errorStyle.Render(IconError + " " + repo.Name + ": " + err.Error()))
+			continue
+		}
+
+		plan, err := gitRepo.PlanRelease(pre)
+		if err != nil {
+			fmt.Println(statusStyle.Render(IconDot + " " + repo.Name + ": " + err.Error()))
+			continue
+		}
+		if err := gitRepo.CreateRelease(plan, push, "origin"); err != nil {
+			fmt.Println(errorStyle.Render(IconError + " " + repo.Name + ": " + err.Error()))
+			continue
+		}
+		fmt.Println(successStyle.Render(fmt.Sprintf("%s %s: %s (%s)", IconSuccess, repo.Name, plan.Next, plan.Bump)))
 	}
+	return nil
 }
 
 // New function to process repositories one at a time
-func processNextRepository(repo Repository, config Config) tea.Cmd {
+func processNextRepository(ctx context.Context, repo Repository, config Config) tea.Cmd {
 	return func() tea.Msg {
-		success, message, operations, logs := processRepositoryWithLogs(repo, config)
+		success, message, operations, logs := processRepositoryWithLogs(ctx, repo, config)
 		return repoProcessedMsg{
 			repo:       repo,
 			success:    success,
@@ -612,7 +686,7 @@ func processNextRepository(repo Repository, config Config) tea.Cmd {
 	}
 }
 
-func processRepositoryWithLogs(repo Repository, config Config) (bool, string, []string, []LogEntry) {
+func processRepositoryWithLogs(ctx context.Context, repo Repository, config Config) (bool, string, []string, []LogEntry) {
 	var logs []LogEntry
 	var operations []string
 
@@ -628,21 +702,20 @@ func processRepositoryWithLogs(repo Repository, config Config) (bool, string, []
 
 	addLog("INFO", "Starting repository processing", IconProcess)
 
-	// Change to repository directory
-	originalDir, _ := os.Getwd()
-	defer os.Chdir(originalDir)
-
-	if err := os.Chdir(repo.Path); err != nil {
-		addLog("ERROR", fmt.Sprintf("Failed to change directory: %v", err), IconError)
-		return false, fmt.Sprintf("Failed to change directory: %v", err), operations, logs
+	// Every git command runs in the repository, the process stays where it is
+	gitRepo, err := src.OpenRepo(repo.Path)
+	if err != nil {
+		addLog("ERROR", fmt.Sprintf("Failed to open repository: %v", err), IconError)
+		return false, fmt.Sprintf("Failed to open repository: %v", err), operations, logs
 	}
+	gitRepo = gitRepo.WithContext(ctx)
 
-	addLog("INFO", fmt.Sprintf("Changed to directory: %s", repo.Path), IconFolder)
+	addLog("INFO", fmt.Sprintf("Opened repository: %s", gitRepo.Path), IconFolder)
 
 	// Pull changes if requested
 	if config.Pull {
 		addLog("INFO", "Pulling changes from remote", IconPull)
-		if err := runGitCommand("pull"); err != nil {
+		if err := gitRepo.Pull(); err != nil {
 			addLog("ERROR", fmt.Sprintf("Failed to pull: %v", err), IconError)
 			return false, fmt.Sprintf("Failed to pull: %v", err), operations, logs
 		}
@@ -664,7 +737,7 @@ func processRepositoryWithLogs(repo Repository, config Config) (bool, string, []
 	// Remove .DS_Store files
 	if config.RemoveDSStore {
 		addLog("INFO", "Searching for .DS_Store files", IconRemove)
-		count, err := removeDSStoreFiles(repo.Path)
+		count, err := removeDSStoreFiles(gitRepo)
 		if err != nil {
 			addLog("ERROR", fmt.Sprintf("Failed to remove .DS_Store files: %v", err), IconError)
 			return false, fmt.Sprintf("Failed to remove .DS_Store files: %v", err), operations, logs
@@ -679,7 +752,7 @@ func processRepositoryWithLogs(repo Repository, config Config) (bool, string, []
 
 	// Check for changes
 	addLog("INFO", "Checking for uncommitted changes", IconSync)
-	hasChanges, err := hasUncommittedChanges()
+	hasChanges, err := gitRepo.HasChanges()
 	if err != nil {
 		addLog("ERROR", fmt.Sprintf("Failed to check for changes: %v", err), IconError)
 		return false, fmt.Sprintf("Failed to check for changes: %v", err), operations, logs
@@ -694,12 +767,31 @@ func processRepositoryWithLogs(repo Repository, config Config) (bool, string, []
 
 	// Stage changes
 	addLog("INFO", "Staging changes", IconAdd)
-	if err := runGitCommand("add", "."); err != nil {
+	if err := gitRepo.Add(); err != nil {
 		addLog("ERROR", fmt.Sprintf("Failed to stage changes: %v", err), IconError)
 		return false, fmt.Sprintf("Failed to stage changes: %v", err), operations, logs
 	}
 	addLog("SUCCESS", "Successfully staged changes", IconSuccess)
 
+	// Scan what is about to be committed for credentials
+	addLog("INFO", "Scanning staged changes for secrets", IconSync)
+	if err := gitRepo.ScanStaged(); err != nil {
+		var secrets *src.SecretsError
+		if !errors.As(err, &secrets) {
+			addLog("ERROR", fmt.Sprintf("Failed to scan for secrets: %v", err), IconError)
+			return false, fmt.Sprintf("Failed to scan for secrets: %v", err), operations, logs
+		}
+		for _, finding := range secrets.Findings {
+			addLog("ERROR", "Possible secret: "+finding.String(), IconWarning)
+		}
+		// Leave the offending files unstaged rather than ready to be
+		// committed, the rest stays staged
+		gitRepo.Git(append([]string{"reset", "-q", "--"}, secrets.Files()...)...)
+		message := fmt.Sprintf("Blocked: %d possible secrets, see %s", len(secrets.Findings), src.AllowlistFile)
+		addLog("ERROR", message, IconError)
+		return false, message, operations, logs
+	}
+
 	// Commit changes
 	commitMessage := config.CommitMessage
 	if commitMessage == "auto-commit" {
@@ -707,31 +799,78 @@ func processRepositoryWithLogs(repo Repository, config Config) (bool, string, []
 		addLog("INFO", fmt.Sprintf("Generated commit message: %s", commitMessage), IconSparkles)
 	}
 
+	aiCommitted := false
 	if config.UseAICommit {
 		addLog("INFO", "Using AI commit command", IconSparkles)
 		// Use ai_commit command
-		cmd := exec.Command("ai_commit", commitMessage)
+		cmd := exec.CommandContext(ctx, "ai_commit", "--yes", commitMessage)
+		cmd.Dir = gitRepo.Path
 		if err := cmd.Run(); err != nil {
-			addLog("ERROR", fmt.Sprintf("ai_commit failed: %v", err), IconError)
-			return false, fmt.Sprintf("ai_commit failed: %v", err), operations, logs
+			if ctx.Err() != nil {
+				addLog("ERROR", "Cancelled", IconError)
+				return false, "Cancelled", operations, logs
+			}
+			addLog("WARNING", fmt.Sprintf("ai_commit failed: %v, falling back to a generated message", err), IconWarning)
+		} else {
+			aiCommitted = true
+			addLog("SUCCESS", "AI commit completed successfully", IconSuccess)
 		}
-		addLog("SUCCESS", "AI commit completed successfully", IconSuccess)
-	} else {
-		// Manual commit
-		addLog("INFO", "Committing changes", IconCommit)
-		if err := runGitCommand("commit", "-m", commitMessage); err != nil {
-			addLog("ERROR", fmt.Sprintf("Failed to commit: %v", err), IconError)
-			return false, fmt.Sprintf("Failed to commit: %v", err), operations, logs
+	}
+
+	if !aiCommitted {
+		// ai_commit may have failed after committing, only commit what is left
+		stillChanged, err := gitRepo.HasChanges()
+		if err != nil {
+			addLog("ERROR", fmt.Sprintf("Failed to check for changes: %v", err), IconError)
+			return false, fmt.Sprintf("Failed to check for changes: %v", err), operations, logs
+		}
+
+		if stillChanged {
+			if err := gitRepo.Add(); err != nil {
+				addLog("ERROR", fmt.Sprintf("Failed to stage changes: %v", err), IconError)
+				return false, fmt.Sprintf("Failed to stage changes: %v", err), operations, logs
+			}
+
+			addLog("INFO", "Committing changes", IconCommit)
+			if err := gitRepo.Commit(commitMessage, src.Options{}); err != nil {
+				addLog("ERROR", fmt.Sprintf("Failed to commit: %v", err), IconError)
+				return false, fmt.Sprintf("Failed to commit: %v", err), operations, logs
+			}
+			addLog("SUCCESS", "Successfully committed changes", IconSuccess)
+		}
+
+		// Work directories must not push commits made with another email
+		if err := gitRepo.CheckIdentity(); err != nil {
+			var identity *src.IdentityError
+			if !errors.As(err, &identity) {
+				addLog("ERROR", fmt.Sprintf("Failed to check the commit identity: %v", err), IconError)
+				return false, fmt.Sprintf("Failed to check the commit identity: %v", err), operations, logs
+			}
+			for _, commit := range identity.Commits {
+				addLog("ERROR", "Wrong author email: "+commit, IconWarning)
+			}
+			message := fmt.Sprintf("Blocked: %d unpushed commits are not authored with %s", len(identity.Commits), identity.Expected)
+			addLog("ERROR", message, IconError)
+			return false, message, operations, logs
 		}
-		addLog("SUCCESS", "Successfully committed changes", IconSuccess)
 
 		// Push changes
 		addLog("INFO", "Pushing changes to remote", IconPush)
-		if err := runGitCommand("push"); err != nil {
-			addLog("ERROR", fmt.Sprintf("Failed to push: %v", err), IconError)
-			return false, fmt.Sprintf("Failed to push: %v", err), operations, logs
+		push := gitRepo.PushBranch()
+		switch push.Status {
+		case src.PushProtected:
+			message := "Blocked: " + push.Err.Error()
+			addLog("ERROR", message, IconError)
+			return false, message, operations, logs
+		case src.PushPushed, src.PushUpToDate, src.PushRebased:
+			if push.Status == src.PushRebased {
+				operations = append(operations, "rebased onto remote changes")
+			}
+			addLog("SUCCESS", "Push: "+push.String(), IconSuccess)
+		default:
+			addLog("ERROR", fmt.Sprintf("Failed to push: %v", push.Err), IconError)
+			return false, fmt.Sprintf("Failed to push (%s): %v", push.Status, push.Err), operations, logs
 		}
-		addLog("SUCCESS", "Successfully pushed changes", IconSuccess)
 	}
 
 	operations = append(operations, "committed and pushed changes")
@@ -751,33 +890,15 @@ func contains(slice []string, item string) bool {
 }
 
 func getCurrentBranch(repoPath string) string {
-	originalDir, _ := os.Getwd()
-	defer os.Chdir(originalDir)
-
-	os.Chdir(repoPath)
-
-	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	output, err := cmd.Output()
+	gitRepo, err := src.OpenRepo(repoPath)
 	if err != nil {
 		return "unknown"
 	}
-
-	return strings.TrimSpace(string(output))
-}
-
-func runGitCommand(args ...string) error {
-	cmd := exec.Command("git", args...)
-	return cmd.Run()
-}
-
-func hasUncommittedChanges() (bool, error) {
-	cmd := exec.Command("git", "status", "--porcelain")
-	output, err := cmd.Output()
+	branch, err := gitRepo.Branch()
 	if err != nil {
-		return false, err
+		return "unknown"
 	}
-
-	return len(strings.TrimSpace(string(output))) > 0, nil
+	return branch
 }
 
 func ensureGitignoreHasDSStore(repoPath string) error {
@@ -803,16 +924,16 @@ func ensureGitignoreHasDSStore(repoPath string) error {
 	return os.WriteFile(gitignorePath, []byte(content), 0644)
 }
 
-func removeDSStoreFiles(repoPath string) (int, error) {
+func removeDSStoreFiles(gitRepo *src.Repo) (int, error) {
 	count := 0
-	err := filepath.Walk(repoPath, func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(gitRepo.Path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
 		if info.Name() == ".DS_Store" {
 			// Remove from git tracking
-			exec.Command("git", "rm", "--cached", path).Run()
+			gitRepo.Git("rm", "--cached", "-q", "--", path)
 			// Remove file
 			if err := os.Remove(path); err == nil {
 				count++
@@ -871,6 +992,16 @@ func main() {
 		"List of directories to include (if empty, include all)")
 	flag.BoolVar(&config.UseAICommit, "use-ai-commit", true,
 		"Use the ai_commit command")
+	installHooks := flag.Bool("install-hooks", false,
+		"Install the prepare-commit-msg hook in every repository and exit")
+	uninstallHooks := flag.Bool("uninstall-hooks", false,
+		"Remove the prepare-commit-msg hook from every repository and exit")
+	release := flag.Bool("release", false,
+		"Tag the next semantic version of every repository and exit")
+	releasePre := flag.String("release-pre", "",
+		"Pre-release identifier for --release, such as rc or beta")
+	releasePush := flag.Bool("release-push", false,
+		"Push the tags created by --release")
 
 	flag.Parse()
 
@@ -879,6 +1010,20 @@ func main() {
 		config.BaseDir = filepath.Join(os.Getenv("HOME"), config.BaseDir[2:])
 	}
 
+	if *installHooks || *uninstallHooks {
+		if err := manageHooks(config, *installHooks); err != nil {
+			log.Fatal("Failed to read directory", "error", err)
+		}
+		return
+	}
+
+	if *release {
+		if err := releaseAll(config, *releasePre, *releasePush); err != nil {
+			log.Fatal("Failed to read directory", "error", err)
+		}
+		return
+	}
+
 	// Initialize and run the Bubble Tea program
 	p := tea.NewProgram(initialModel(config), tea.WithAltScreen())
 